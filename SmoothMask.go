@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type SmoothingMask struct {
@@ -81,3 +82,55 @@ func boolToInt(b bool) int {
 	}
 	return 0
 }
+
+// Lines returns the lines on which smoothing is on.
+func (s SmoothingMask) Lines() LineMask {
+	var m LineMask
+	for _, l := range []struct {
+		on   bool
+		line LineMask
+	}{
+		{s.Mic1, LineMic1}, {s.Mic2, LineMic2},
+		{s.Opto1, LineOpto1}, {s.Opto2, LineOpto2}, {s.Opto3, LineOpto3}, {s.Opto4, LineOpto4},
+	} {
+		if l.on {
+			m |= l.line
+		}
+	}
+	return m
+}
+
+// SmoothingCorrection corrects the events of the lines on which smoothing was on during a
+// capture: the BBTK then detects their offsets Latency too late (see Capabilities.SmoothingLatency).
+type SmoothingCorrection struct {
+	Mask    SmoothingMask
+	Latency time.Duration
+}
+
+// Apply shortens by c.Latency the durations of the events of the smoothed lines, and records the
+// correction in their SmoothingCorrection field. The events already corrected are left alone,
+// as are those still active at the end of the capture, whose offset was not seen. A duration is
+// never made negative.
+func (c SmoothingCorrection) Apply(events []Event) {
+	lines := c.Mask.Lines()
+	latency := c.Latency.Microseconds()
+	for i := range events {
+		e := &events[i]
+		if l, ok := LineByName(e.Type); !ok || lines&l == 0 || e.ActiveAtEnd || e.SmoothingCorrection != 0 {
+			continue
+		}
+		e.SmoothingCorrection = min(latency, e.Duration)
+		e.Duration -= e.SmoothingCorrection
+	}
+}
+
+// CaptureEventsWithSmoothing is CaptureEventsFromDSCEvents followed by the correction c of the
+// latency of smoothing (e.g. CaptureResult.Smoothing).
+func CaptureEventsWithSmoothing(rawEvents []DSCEvent, c SmoothingCorrection) ([]Event, error) {
+	events, err := CaptureEventsFromDSCEvents(rawEvents)
+	if err != nil {
+		return nil, err
+	}
+	c.Apply(events)
+	return events, nil
+}
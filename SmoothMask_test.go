@@ -0,0 +1,76 @@
+package bbtkv3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSmoothingCorrectionApply(t *testing.T) {
+	c := SmoothingCorrection{Mask: SmoothingMask{Mic1: true, Opto1: true}, Latency: 20 * time.Millisecond}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  Event
+	}{
+		{
+			"smoothed line",
+			Event{Type: "Opto1", Onset: 1000, Duration: 50000},
+			Event{Type: "Opto1", Onset: 1000, Duration: 30000, SmoothingCorrection: 20000},
+		},
+		{
+			"line without smoothing",
+			Event{Type: "Opto2", Onset: 1000, Duration: 50000},
+			Event{Type: "Opto2", Onset: 1000, Duration: 50000},
+		},
+		{
+			"keypad line",
+			Event{Type: "Keypad1", Onset: 1000, Duration: 50000},
+			Event{Type: "Keypad1", Onset: 1000, Duration: 50000},
+		},
+		{
+			"shorter than the latency",
+			Event{Type: "Mic1", Onset: 1000, Duration: 15000},
+			Event{Type: "Mic1", Onset: 1000, Duration: 0, SmoothingCorrection: 15000},
+		},
+		{
+			"active at end",
+			Event{Type: "Mic1", Onset: 1000, Duration: 50000, ActiveAtEnd: true},
+			Event{Type: "Mic1", Onset: 1000, Duration: 50000, ActiveAtEnd: true},
+		},
+		{
+			"already corrected",
+			Event{Type: "Opto1", Onset: 1000, Duration: 30000, SmoothingCorrection: 20000},
+			Event{Type: "Opto1", Onset: 1000, Duration: 30000, SmoothingCorrection: 20000},
+		},
+	}
+
+	for _, tt := range tests {
+		events := []Event{tt.event}
+		c.Apply(events)
+		if events[0] != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, events[0], tt.want)
+		}
+		c.Apply(events) // never applied twice
+		if events[0] != tt.want {
+			t.Errorf("%s: applied twice: got %+v, want %+v", tt.name, events[0], tt.want)
+		}
+	}
+}
+
+func TestCaptureEventsWithSmoothing(t *testing.T) {
+	raw := []DSCEvent{{100000, LineMic1 | LineMic2}, {200000, 0}}
+	events, err := CaptureEventsWithSmoothing(raw, SmoothingCorrection{Mask: SmoothingMask{Mic2: true}, Latency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{Type: "Mic2", Onset: 100000, Duration: 80000, SmoothingCorrection: 20000},
+		{Type: "Mic1", Onset: 100000, Duration: 100000},
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
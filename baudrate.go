@@ -0,0 +1,84 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// AutoBaudrate, passed as baudrate to NewBbtkv3 or Discover, makes them detect the rate of the BBTK.
+const AutoBaudrate = 0
+
+// KnownBaudrates are the rates tried, in order, to detect the rate of a BBTK: the default rates
+// of the BBTKv3 and of the BBTKv2 first (see Capabilities.DefaultBaudrate), then other standard rates.
+var KnownBaudrates = []int{
+	CapabilitiesOf(ModelV3).DefaultBaudrate,
+	CapabilitiesOf(ModelV2).DefaultBaudrate,
+	57600, 38400, 19200, 9600,
+}
+
+// BaudrateProbeTimeout is how long the handshake is attempted at each rate when detecting the rate.
+var BaudrateProbeTimeout = time.Second
+
+// Baudrate returns the rate of the serial port to the BBTK (0 for other transports).
+func (b *Bbtkv3) Baudrate() int {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.baudrate
+}
+
+// DetectBaudrate opens the serial port at portAddress at each of the KnownBaudrates in turn, until
+// the BBTK answers the handshake (CONN, then ECHO), and returns the BBTK connected at that rate.
+func DetectBaudrate(ctx context.Context, portAddress string, logger *slog.Logger) (*Bbtkv3, error) {
+	b, err := detectBaudrate(ctx, portAddress, time.Time{}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("DetectBaudrate: %w", err)
+	}
+	return b, nil
+}
+
+// detectBaudrate is DetectBaudrate giving up at deadline, if it is not zero.
+func detectBaudrate(ctx context.Context, portAddress string, deadline time.Time, logger *slog.Logger) (*Bbtkv3, error) {
+	if logger == nil {
+		logger = discardLogger
+	}
+
+	var errs []error
+	for _, rate := range KnownBaudrates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		b, err := NewBbtkv3(portAddress, rate, logger)
+		if err != nil {
+			return nil, err // the port cannot be opened: other rates will not do better
+		}
+
+		limit := time.Now().Add(BaudrateProbeTimeout)
+		if !deadline.IsZero() {
+			limit = earliest(limit, deadline)
+		}
+		b.ResetSerialBuffers()
+		err = b.connect(limit)
+		if err == nil {
+			err = b.sync("CONN")
+		}
+		if err == nil {
+			b.logger.Info("baud rate detected", "baudrate", rate)
+			return b, nil
+		}
+
+		b.logger.Debug("no handshake", "baudrate", rate, "err", err)
+		errs = append(errs, fmt.Errorf("at %d bps: %w", rate, err))
+		b.Disconnect()
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("no BBTK answered at %s: %w", portAddress, errors.Join(errs...))
+}
@@ -0,0 +1,272 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// How long to wait, after the end of the capture, for the BBTK to start sending its data,
+// and how long a silence is tolerated in the middle of the download.
+var (
+	CaptureStartTimeout = 10 * time.Second
+	CaptureIdleTimeout  = 5 * time.Second
+)
+
+// ArmSettleDelay is left after the commands arming a capture (DSCM, TIML and the duration),
+// for the BBTK to process them before RUDS, as the Python drivers do. If it is 0, ECHO is
+// sent after DSCM and after TIML instead, and its answer waited for: arming is then faster,
+// but only the simulator is known to answer ECHO in DSCM mode.
+var ArmSettleDelay = 500 * time.Millisecond
+
+// CaptureStage tells where a capture stands.
+type CaptureStage int
+
+const (
+	CaptureArming      CaptureStage = iota // sending DSCM, TIML and the duration
+	CaptureArmed                           // ready to start
+	CaptureRunning                         // RUDS sent, the BBTK is recording
+	CaptureDownloading                     // the BBTK is sending its data
+	CaptureDone                            // EDAT received
+)
+
+func (s CaptureStage) String() string {
+	switch s {
+	case CaptureArming:
+		return "arming"
+	case CaptureArmed:
+		return "armed"
+	case CaptureRunning:
+		return "running"
+	case CaptureDownloading:
+		return "downloading"
+	case CaptureDone:
+		return "done"
+	}
+	return fmt.Sprintf("CaptureStage(%d)", int(s))
+}
+
+// CaptureProgress is reported while a capture runs.
+type CaptureProgress struct {
+	Stage   CaptureStage
+	Elapsed time.Duration // time since the capture was started (RUDS)
+	Bytes   int           // number of bytes downloaded so far
+	Events  int           // number of DSC events downloaded so far
+}
+
+// CaptureResult holds the outcome of a capture.
+type CaptureResult struct {
+	Header DSCMHeader // as sent by the BBTK
+	Events []DSCEvent
+	Start  time.Time // when RUDS was sent, according to the clock of the host
+	Late   int       // number of events stamped after the end of the capture (Header.Duration)
+
+	// Smoothing is the correction of the latency of the smoothing active during the capture,
+	// to be applied to the events (see CaptureEventsWithSmoothing). It is nil if the smoothing
+	// was not set through the Bbtkv3 object, and thus is unknown.
+	Smoothing *SmoothingCorrection
+}
+
+// CaptureSession is a capture running in the background, started by StartCapture.
+type CaptureSession struct {
+	progress chan CaptureProgress
+	done     chan struct{}
+	result   *CaptureResult
+	err      error
+}
+
+// Progress returns a channel on which the progress of the capture is reported.
+// It is closed when the capture ends. Reports are dropped when the channel is not
+// drained fast enough; the outcome of the capture is always available from Wait.
+func (c *CaptureSession) Progress() <-chan CaptureProgress {
+	return c.progress
+}
+
+// Done returns a channel which is closed when the capture ends.
+func (c *CaptureSession) Done() <-chan struct{} {
+	return c.done
+}
+
+// Wait waits for the end of the capture and returns its result.
+func (c *CaptureSession) Wait() (*CaptureResult, error) {
+	<-c.done
+	return c.result, c.err
+}
+
+func (c *CaptureSession) report(p CaptureProgress) {
+	select {
+	case c.progress <- p:
+	default:
+	}
+}
+
+// StartCapture launches, in the background, a capture of all the events occurring on the
+// input lines of the BBTK for the given duration (with microsecond resolution).
+// The events are parsed as they are downloaded; if raw is not nil, the text sent
+// by the BBTK is also copied to it.
+//
+// Cancelling ctx stops the wait for the data. Note that the BBTK keeps capturing until
+// the programmed duration has elapsed, then sends its data: call ResetSerialBuffers
+// before issuing other commands.
+//
+// The other goroutines using b wait until the end of the capture.
+func (b *Bbtkv3) StartCapture(ctx context.Context, duration time.Duration, raw io.Writer) (*CaptureSession, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("StartCapture: invalid duration %v", duration)
+	}
+
+	c := &CaptureSession{
+		progress: make(chan CaptureProgress, 64),
+		done:     make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	go func() {
+		defer close(c.done)
+		defer close(c.progress)
+		defer b.unlock()
+		c.result, c.err = b.runCapture(ctx, duration, raw, c)
+	}()
+
+	return c, nil
+}
+
+// arm sends to the BBTK the commands preparing a capture in DSCM mode, and waits
+// until it has processed them (see ArmSettleDelay).
+func (b *Bbtkv3) arm(duration time.Duration) error {
+	if err := b.send("DSCM"); err != nil {
+		return fmt.Errorf("DSCM: %w", err)
+	}
+	if ArmSettleDelay == 0 {
+		if err := b.sync("DSCM"); err != nil {
+			return err
+		}
+	} else {
+		time.Sleep(ArgumentDelay)
+	}
+
+	if err := b.sendWithArgs("TIML", fmt.Sprintf("%d", duration.Microseconds())); err != nil {
+		return fmt.Errorf("TIML: %w", err)
+	}
+	if ArmSettleDelay == 0 {
+		return b.sync("TIML")
+	}
+	time.Sleep(ArmSettleDelay)
+	return nil
+}
+
+func (b *Bbtkv3) runCapture(ctx context.Context, duration time.Duration, raw io.Writer, c *CaptureSession) (*CaptureResult, error) {
+	c.report(CaptureProgress{Stage: CaptureArming})
+	armStart := time.Now()
+	if err := b.arm(duration); err != nil {
+		return nil, fmt.Errorf("StartCapture: %w", err)
+	}
+	c.report(CaptureProgress{Stage: CaptureArmed})
+	b.logger.Info("capture armed", "duration", duration, "arming", time.Since(armStart))
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := b.run(ctx, duration, raw, c)
+	if err != nil {
+		return nil, fmt.Errorf("StartCapture: %w", err)
+	}
+	return result, nil
+}
+
+// run starts an armed capture and downloads its data.
+func (b *Bbtkv3) run(ctx context.Context, duration time.Duration, raw io.Writer, c *CaptureSession) (*CaptureResult, error) {
+	start := time.Now()
+	if err := b.send("RUDS"); err != nil {
+		return nil, fmt.Errorf("RUDS: %w", err)
+	}
+	r := &captureReader{ctx: ctx, b: b, c: c, raw: raw, duration: duration, start: time.Now()}
+	c.report(CaptureProgress{Stage: CaptureRunning})
+	b.logger.Info("capture started")
+
+	var events []DSCEvent
+	d := NewDSCMReader(r)
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+		r.events = len(events)
+	}
+
+	c.report(CaptureProgress{Stage: CaptureDone, Elapsed: time.Since(r.start), Bytes: r.bytes, Events: len(events)})
+	b.logger.Info("capture downloaded", "bytes", r.bytes, "events", len(events), "elapsed", time.Since(r.start))
+	header, _ := d.Header()
+	result := &CaptureResult{Header: header, Events: events, Start: start, Late: d.Late()}
+	if result.Late > 0 {
+		b.logger.Warn("events stamped after the end of the capture", "events", result.Late, "duration", header.Duration)
+	}
+	if b.smoothing != nil {
+		result.Smoothing = &SmoothingCorrection{Mask: *b.smoothing, Latency: b.caps.SmoothingLatency}
+		if b.smoothingLatency != nil {
+			result.Smoothing.Latency = *b.smoothingLatency
+		}
+	}
+	return result, nil
+}
+
+// captureReader reads the data of a capture from the BBTK, copying it to raw, reporting
+// progress and watching for cancellation and timeouts while the BBTK is silent.
+type captureReader struct {
+	ctx      context.Context
+	b        *Bbtkv3
+	c        *CaptureSession
+	raw      io.Writer
+	duration time.Duration
+	start    time.Time
+	lastData time.Time
+	bytes    int
+	events   int
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		n, err := r.b.reader.Read(p)
+		if err != nil && !errors.Is(err, ErrTimeout) {
+			return 0, r.b.checkLost(err)
+		}
+
+		elapsed := time.Since(r.start)
+
+		if n > 0 {
+			if r.raw != nil {
+				if _, err := r.raw.Write(p[:n]); err != nil {
+					return 0, err
+				}
+			}
+			if r.bytes == 0 {
+				r.b.logger.Debug("download started", "elapsed", elapsed)
+			}
+			r.bytes += n
+			r.lastData = time.Now()
+			r.c.report(CaptureProgress{Stage: CaptureDownloading, Elapsed: elapsed, Bytes: r.bytes, Events: r.events})
+			return n, nil
+		}
+
+		// read timeout
+		if r.bytes == 0 {
+			if elapsed > r.duration+CaptureStartTimeout {
+				return 0, fmt.Errorf("%w: no data received from the BBTK", ErrTimeout)
+			}
+			r.c.report(CaptureProgress{Stage: CaptureRunning, Elapsed: elapsed})
+		} else if time.Since(r.lastData) > CaptureIdleTimeout {
+			return 0, fmt.Errorf("%w: download interrupted after %d bytes", ErrTimeout, r.bytes)
+		}
+	}
+}
@@ -13,13 +13,15 @@
 //   -p string
 //         device (serial port name) (default "/dev/ttyUSB0")
 //   -b int
-//         baudrate (speed in bps) (default 115200)
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
 //   -d int
 //         duration of capture (in s) (default 30)
 //   -o string
 //         output file name for captured data (default "bbtk-capture.dat")
+//   -v
+//         Verbose mode
 //   -D
-//         Debug mode (default false)
+//         Debug mode (log the serial protocol)
 //   -V
 //         Display version
 
@@ -32,9 +34,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
 	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
 )
 
 // Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
@@ -51,35 +53,39 @@ var (
 func main() {
 
 	portPtr := flag.String("p", PortAddress, "device (serial port name)")
-	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
 	versionPtr := flag.Bool("V", false, "Display version")
 
 	flag.Parse()
 
 	if *versionPtr {
-		fmt.Printf("Version: %s  Build: %s\n", Version, Build[:8])
+		buildinfo.Print(Version, Build)
 		os.Exit(0)
 	}
 
-	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, false)
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer b.Disconnect()
 
-	time.Sleep(100 * time.Millisecond)
-
 	// HandShaking
 	if err = b.Connect(); err != nil {
 		log.Fatalf("Connect returned: %v\n", err)
 	}
-	time.Sleep(100 * time.Millisecond)
 
 	fmt.Println("Connected to the BBTKv3. Getting thresholds...")
-	b.GetThresholds()
+	if _, err = b.GetThresholds(); err != nil {
+		log.Fatalln(err)
+	}
 
 	fmt.Println("The BBTKv3 is now in Threshold setting mode...")
-	b.AdjustThresholds()
+	if err = b.AdjustThresholds(); err != nil {
+		log.Fatalln(err)
+	}
 
 	// Not necessary as defer will take care of it
 	//if err = b.Disconnect(); err != nil {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+)
+
+// captureGroup captures events on several BBTKs at once, started together, saving the
+// data of each one to files named after basename with the suffix -box1, -box2...
+func captureGroup(ports []string, baudrate int, duration int, basename string, logger *slog.Logger) {
+	configs := make([]bbtkv3.DeviceConfig, len(ports))
+	for i, port := range ports {
+		configs[i] = bbtkv3.DeviceConfig{Port: port, Baudrate: baudrate, Smoothing: &defaultSmoothingMask, SmoothingLatency: smoothingLatency}
+	}
+
+	fmt.Printf("Opening %v...\n", ports)
+	g, err := bbtkv3.OpenGroup(configs, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer g.Close()
+
+	fmt.Printf("Clearing Timing data... ")
+	if err = g.ClearTimingData(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Ok")
+
+	ext := filepath.Ext(basename)
+	files := make([]*os.File, len(ports))
+	raw := make([]io.Writer, len(ports))
+	for i := range ports {
+		f, err := CreateNextFile(fmt.Sprintf("%s-box%d%s", strings.TrimSuffix(basename, ext), i+1, ext))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		files[i] = f
+		raw[i] = f
+	}
+
+	fmt.Printf("Capturing events (with DSCM) on %d BBTKs for %v seconds... ", len(ports), duration)
+	result, err := g.Capture(context.Background(), time.Duration(duration)*time.Second, raw)
+	if result == nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("done")
+	fmt.Printf("Start skew between the BBTKs: %v\n", result.ArmSkew)
+	if err != nil {
+		log.Println(err)
+	}
+
+	failed := 0
+	for i, r := range result.Results {
+		if r == nil {
+			log.Printf("box %d (%s): capture failed, no events saved (the data received are in %s)\n", i+1, ports[i], files[i].Name())
+			failed++
+			continue
+		}
+		fmt.Printf("%s: raw Data saved to %s\n", ports[i], files[i].Name())
+		saveEvents(files[i].Name(), r)
+	}
+	if failed > 0 {
+		for _, f := range files {
+			f.Close()
+		}
+		log.Fatalf("the capture failed on %d of %d BBTKs\n", failed, len(ports))
+	}
+}
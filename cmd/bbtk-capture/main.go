@@ -11,33 +11,40 @@
 //
 // Usage:
 //   -p string
-//         device (serial port name) (default "/dev/ttyUSB0")
+//         device (serial port name) (default "/dev/ttyUSB0"); repeat it to capture on several BBTKs at once
 //   -b int
-//         baudrate (speed in bps) (default 115200)
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
 //   -d int
 //         duration of capture (in s) (default 30)
 //   -o string
 //         output file name for captured data (default "bbtk-capture.dat")
+//   -unit string
+//         unit of the times in the CSV files: us, ms or s (default "ms")
+//   -record string
+//         record the serial traffic in a transcript file (to attach to bug reports)
+//   -v
+//         Verbose mode
 //   -D
-//         Debug mode (default false)
+//         Debug mode (log the serial protocol)
 //   -V
 //         Display version
 
 // TODO: implement adjustable thresholds, reading the thresholds form the command line or from a configuration file
 // TODO: better handle errors
-// TODO: The way I handle DEBUG is a disaster, implement verbose and debug with 2 level logs.
-//        THe module bbtkv3 uses the env at
 
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"time"
 
 	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
 )
 
 // Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
@@ -51,9 +58,14 @@ var (
 	Baudrate       = 115200
 	Duration       = 30
 	OutputFileName = "bbtk-capture.dat"
-	DEBUG          = false
 )
 
+// timeUnit is the unit of the times in the CSV files.
+var timeUnit = bbtkv3.Milliseconds
+
+// smoothingLatency, if not nil, replaces the latency of smoothing of the model of the BBTK.
+var smoothingLatency *time.Duration
+
 var defaultSmoothingMask = bbtkv3.SmoothingMask{
 	Mic1:  true,
 	Mic2:  true,
@@ -65,38 +77,76 @@ var defaultSmoothingMask = bbtkv3.SmoothingMask{
 
 func main() {
 
-	portPtr := flag.String("p", PortAddress, "device (serial port name)")
-	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps)")
+	var ports portList
+	flag.Var(&ports, "p", fmt.Sprintf("device (serial port name) (default %q); repeat it to capture on several BBTKs at once", PortAddress))
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
 	durationPtr := flag.Int("d", Duration, "duration of capture (in s)")
 	outputFilenamePtr := flag.String("o", OutputFileName, "output file name for captured data")
-	debugPtr := flag.Bool("D", DEBUG, "Debug mode")
+	unitPtr := flag.String("unit", timeUnit.String(), "unit of the times in the CSV files: us, ms or s")
+	flag.Func("smoothing-latency", "latency of smoothing subtracted from the durations, e.g. 20ms (default: that of the model of the BBTK)", func(s string) error {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		smoothingLatency = &d
+		return nil
+	})
+	recordPtr := flag.String("record", "", "record the serial traffic in a transcript file (to attach to bug reports)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
 	versionPtr := flag.Bool("V", false, "Display version")
 
 	flag.Parse()
 
 	if *versionPtr {
-		fmt.Printf("Version: %s  Build: %s\n", Version, Build[:8])
+		buildinfo.Print(Version, Build)
 		os.Exit(0)
 	}
 
-	DEBUG = *debugPtr
+	var err error
+	if timeUnit, err = bbtkv3.ParseTimeUnit(*unitPtr); err != nil {
+		log.Fatalln(err)
+	}
 
-	serPort := ""
-	if *portPtr != "" {
-		serPort = *portPtr
-	} else {
+	if len(ports) == 0 {
+		ports = portList{PortAddress}
+	}
+	serPort := ports[0]
+	if serPort == "" {
 		serPort = bbtkv3.GetPortFromEnv()
 	}
 
 	// Initialisation
-	verbose := true
-	b, err := bbtkv3.NewBbtkv3(serPort, *speedPtr, verbose)
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+
+	if len(ports) > 1 {
+		if *recordPtr != "" {
+			log.Fatalln("-record cannot be used with several ports")
+		}
+		captureGroup(ports, *speedPtr, *durationPtr, *outputFilenamePtr, logger)
+		return
+	}
+	var b *bbtkv3.Bbtkv3
+	if *recordPtr == "" {
+		b, err = bbtkv3.NewBbtkv3(serPort, *speedPtr, logger)
+	} else {
+		b, err = openRecording(serPort, *speedPtr, *recordPtr, logger)
+	}
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer b.Disconnect()
 
-	time.Sleep(time.Second)
+	// a recorded session cannot be reopened: the loss of the port is then only reported
+	if *recordPtr == "" {
+		b.SetAutoReconnect(true, func(e bbtkv3.ReconnectEvent) {
+			if e.Err != nil {
+				log.Printf("lost the BBTK and could not reconnect: %v\n", e.Err)
+			} else {
+				log.Printf("lost the BBTK, reconnected at %s\n", e.Port)
+			}
+		})
+	}
 
 	err = b.ResetSerialBuffers()
 	if err != nil {
@@ -107,7 +157,9 @@ func main() {
 	if err = b.Connect(); err != nil {
 		log.Fatalf("Connect returned: %v\n", err)
 	}
-	time.Sleep(time.Second)
+	if *speedPtr == bbtkv3.AutoBaudrate {
+		fmt.Printf("Connected at %d bps\n", b.Baudrate())
+	}
 
 	err = b.ResetSerialBuffers()
 	if err != nil {
@@ -124,65 +176,101 @@ func main() {
 			fmt.Println("bbtkv3 not responding to ECHO")
 		}
 	}
-	time.Sleep(time.Second)
 
 	// Parameters setting
+	if smoothingLatency != nil {
+		b.SetSmoothingLatency(*smoothingLatency)
+	}
 	fmt.Printf("Setting Smoothing mask to %+v\n", defaultSmoothingMask)
 	if err = b.SetSmoothing(defaultSmoothingMask); err != nil {
 		log.Printf("%v", err)
 	}
-	time.Sleep(time.Second)
 
 	fmt.Println("Getting thresholds...")
-	fmt.Printf("%+v\n", b.GetThresholds())
+	thresholds, err := b.GetThresholds()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%+v\n", thresholds)
 
 	// Clearing internal memory
-	time.Sleep(time.Second)
 	fmt.Printf("Clearing Timing data... ")
-	b.ClearTimingData()
+	if err = b.ClearTimingData(); err != nil {
+		log.Fatalln(err)
+	}
 	fmt.Println("Ok")
 
 	// Data Capture
-	time.Sleep(1 * time.Second)
-	fmt.Printf("Capturing events (with DSCM) for %v seconds... ", *durationPtr)
-	data := b.CaptureEvents(*durationPtr)
-	fmt.Println("ok!")
-
-	fname, err := WriteText(*outputFilenamePtr, data)
+	rawFile, err := CreateNextFile(*outputFilenamePtr)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Printf("Raw Data saved to %s\n", fname)
+	defer rawFile.Close()
+	fname := rawFile.Name()
 
-	dscEvents, err := bbtkv3.CaptureOutputToEvents(data)
+	fmt.Printf("Capturing events (with DSCM) for %v seconds... ", *durationPtr)
+	capture, err := b.StartCapture(context.Background(), time.Duration(*durationPtr)*time.Second, rawFile)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	lastCount := -1
+	for p := range capture.Progress() {
+		switch p.Stage {
+		case bbtkv3.CaptureRunning:
+			if count := int(math.Ceil((time.Duration(*durationPtr)*time.Second - p.Elapsed).Seconds())); count != lastCount && count > 0 {
+				fmt.Printf("%d ", count)
+				lastCount = count
+			}
+		case bbtkv3.CaptureDownloading:
+			if lastCount != 0 {
+				fmt.Printf("\nDownloading data... ")
+				lastCount = 0
+			}
+		}
+	}
+	result, err := capture.Wait()
+	if err != nil {
+		log.Fatalf("%v (the data received are in %s)\n", err, fname)
+	}
+	fmt.Println("ok!")
+	fmt.Printf("%d events recorded in %v\n", result.Header.Events, time.Duration(result.Header.Duration)*time.Microsecond)
+	fmt.Printf("Raw Data saved to %s\n", fname)
+
+	saveEvents(fname, result)
+
+	// Not necessary as defer will take care of it
+	//if err = b.Disconnect(); err != nil {
+	//	log.Println(err)
+	//}
+
+}
+
+// saveEvents saves the DSC events of a capture, and the events detected in them, next to the raw
+// data file fname. The durations are corrected for the latency of smoothing.
+func saveEvents(fname string, result *bbtkv3.CaptureResult) {
 	efname := changeExtension(fname, "dscevents.csv")
-	err = bbtkv3.SaveDSCEventsToCSV(dscEvents, efname)
+	err := bbtkv3.SaveDSCEventsToCSV(result.Events, efname, timeUnit)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	fmt.Printf("DSC Events saved to %s\n", efname)
 
-	// add a event with all lines set to 0 at the end of dscEvents
-	dscEvents = append(dscEvents, bbtkv3.DSCEvent{})
-
-	events, err := bbtkv3.CaptureEventsFromDSCEvents(dscEvents)
+	if result.Late > 0 {
+		fmt.Printf("Warning: %d events stamped after the end of the capture\n", result.Late)
+	}
+	events, err := bbtkv3.CaptureEventsFromCapture(result)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if c := result.Smoothing; c != nil {
+		c.Apply(events)
+		fmt.Printf("Offsets corrected by %v for smoothing on %s\n", c.Latency, c.Mask.Lines().Describe())
+	}
 
 	eventsFileName := changeExtension(fname, "events.csv")
-	err = bbtkv3.SaveEventsToCSV(events, eventsFileName)
+	err = bbtkv3.SaveEventsToCSV(events, eventsFileName, timeUnit)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	fmt.Printf("Events saved to %s\n", eventsFileName)
-
-	// Not necessary as defer will take care of it
-	//if err = b.Disconnect(); err != nil {
-	//	log.Println(err)
-	//}
-
 }
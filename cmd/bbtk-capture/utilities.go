@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/chrplr/bbtkv3"
 )
 
 // fileExists checks if a file with the given filename exists.
@@ -55,17 +59,46 @@ func GetNextFileName(basename string) string {
 	return filename
 }
 
-func WriteText(basename string, text string) (string, error) {
-	var filename string = GetNextFileName(basename)
+// CreateNextFile creates a new file named after basename (see GetNextFileName)
+func CreateNextFile(basename string) (*os.File, error) {
+	return os.Create(GetNextFileName(basename))
+}
+
+// openRecording opens the BBTK at portAddress, recording all the serial traffic
+// into the transcript file filename. If baudrate is bbtkv3.AutoBaudrate, the rate
+// is detected first, without recording.
+func openRecording(portAddress string, baudrate int, filename string, logger *slog.Logger) (*bbtkv3.Bbtkv3, error) {
+	if baudrate == bbtkv3.AutoBaudrate {
+		b, err := bbtkv3.DetectBaudrate(context.Background(), portAddress, logger)
+		if err != nil {
+			return nil, err
+		}
+		baudrate = b.Baudrate()
+		b.Disconnect()
+	}
 
 	f, err := os.Create(filename)
 	if err != nil {
-		return filename, err
+		return nil, err
 	}
 
-	defer f.Close()
+	port, err := bbtkv3.OpenSerial(portAddress, baudrate)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return bbtkv3.NewBbtkv3FromTransport(bbtkv3.NewRecorder(port, f), logger), nil
+}
 
-	_, err = f.WriteString(text)
+// portList is a flag which can be repeated to give several serial ports.
+type portList []string
+
+func (p *portList) String() string {
+	return strings.Join(*p, ",")
+}
 
-	return filename, err
+func (p *portList) Set(s string) error {
+	*p = append(*p, s)
+	return nil
 }
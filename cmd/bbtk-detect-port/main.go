@@ -1,106 +1,132 @@
 // scan all available serial ports for a BBTK device
-// because some ports can be blocking,
+//
+// Usage:
+//
+//	bbtk-detect-port [flags] [port ...]
+//
+// Without arguments, all the serial ports of the computer are scanned.
+//
+// Exit status: 0 if a BBTK was found, 1 if none was found, 2 in case of error.
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
 	"os"
-	"time"
 
-	"go.bug.st/serial"
+	"github.com/chrplr/bbtkv3"
 )
 
 var (
 	Baudrate = 115200
-	DEBUG    = false
 )
 
-func ReadData(port serial.Port) string {
-	byteBuff := bytes.NewBufferString("")
-	buff := make([]byte, 100)
-
-	err := port.SetReadTimeout(time.Second)
-	if err != nil {
-		panic(err)
-	}
+// jsonResult is the JSON representation of a bbtkv3.DiscoveryResult.
+type jsonResult struct {
+	Port         string `json:"port"`
+	Found        bool   `json:"found"`
+	Firmware     string `json:"firmware,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Baudrate     int    `json:"baudrate,omitempty"`
+	IsUSB        bool   `json:"usb"`
+	VID          string `json:"vid,omitempty"`
+	PID          string `json:"pid,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
 
-	for {
+func main() {
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	timeoutPtr := flag.Duration("t", bbtkv3.DefaultDiscoveryTimeout, "time spent on each port")
+	jsonPtr := flag.Bool("json", false, "print the results in JSON")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
 
-		n, err := port.Read(buff)
-		if err != nil {
-			panic(err)
-		}
+	flag.Parse()
 
-		//0 means we hit the timeout.
-		if n == 0 {
-			return byteBuff.String()
-		}
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
 
-		byteBuff.Write(buff[:n])
-		if DEBUG {
-			fmt.Printf("%s", byteBuff.String())
+	if !*jsonPtr {
+		if flag.NArg() > 0 {
+			fmt.Printf("Scanning %v for a BBTK...\n", flag.Args())
+		} else {
+			fmt.Println("Scanning the serial ports for a BBTK...")
 		}
 	}
-}
 
-func CheckIfBBTKConnectedAt(port serial.Port) bool {
-	_, err := port.Write([]byte("CONN\r\n"))
+	results, err := bbtkv3.Discover(context.Background(), flag.Args(), *speedPtr, *timeoutPtr, logger)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	resp := ReadData(port)
-	return resp[:len(resp)-1] == "BBTK;"
-}
 
-func ScanSerialPortForBBTK(portName string) {
-	mode := &serial.Mode{
-		BaudRate: Baudrate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
+	found := false
+	for _, r := range results {
+		found = found || r.Found
 	}
 
-	p, err := serial.Open(portName, mode)
-	if err != nil {
-		fmt.Println("Error while trying to open", portName, " at ", Baudrate, "bps", err)
-	}
-	defer p.Close()
-	if DEBUG {
-		fmt.Println("Opened port", portName)
+	if *jsonPtr {
+		printJSON(results)
+	} else {
+		printPlain(results, *verbosePtr)
 	}
-	if CheckIfBBTKConnectedAt(p) {
-		fmt.Printf("BBTK found at %v\n", portName)
+
+	if !found {
+		os.Exit(1)
 	}
 }
 
-func main() {
-	var err error
-
-	if _, ok := os.LookupEnv("DEBUG"); ok {
-		DEBUG = true
-		log.Println("DEBUG mode enabled.")
-	} else {
-		DEBUG = false
+func printPlain(results []bbtkv3.DiscoveryResult, verbose bool) {
+	if len(results) == 0 {
+		fmt.Println("No serial ports found!")
+		return
 	}
 
-	portlist := os.Args[1:]
-
-	if len(portlist) == 0 {
-		portlist, err = serial.GetPortsList()
-		if err != nil {
-			log.Fatal(err)
+	found := false
+	for _, r := range results {
+		found = found || r.Found
+		usb := ""
+		if r.IsUSB {
+			usb = fmt.Sprintf(" [USB %s:%s serial %q]", r.VID, r.PID, r.SerialNumber)
 		}
+		if r.Found {
+			fmt.Printf("%v found at %v (%d bps)%s: %s\n", r.Model, r.Port, r.Baudrate, usb, r.Firmware)
+		} else if verbose {
+			fmt.Printf("no BBTK at %v%s: %v\n", r.Port, usb, r.Err)
+		}
+	}
+	if !found {
+		fmt.Printf("No BBTK found on %d serial port(s).\n", len(results))
 	}
+}
 
-	if len(portlist) == 0 {
-		fmt.Println("No serial ports found!")
-	} else {
-		fmt.Printf("Scanning %v for a BBTK...\n", portlist)
-		for _, p := range portlist {
-			go ScanSerialPortForBBTK(p)
+func printJSON(results []bbtkv3.DiscoveryResult) {
+	out := make([]jsonResult, len(results))
+	for i, r := range results {
+		out[i] = jsonResult{
+			Port:         r.Port,
+			Found:        r.Found,
+			Firmware:     r.Firmware,
+			IsUSB:        r.IsUSB,
+			VID:          r.VID,
+			PID:          r.PID,
+			SerialNumber: r.SerialNumber,
+		}
+		if r.Found {
+			out[i].Model = r.Model.String()
+			out[i].Baudrate = r.Baudrate
 		}
+		if r.Err != nil {
+			out[i].Error = r.Err.Error()
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	time.Sleep(2. * time.Second)
 }
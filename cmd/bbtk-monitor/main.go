@@ -0,0 +1,127 @@
+// Show the state of the input lines of a BlackBoxToolKit live
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package main provides bbtk-monitor, a command-line tool which puts the BBTK in input check
+// mode (ICHK) and displays the state of its 12 input lines as they change. This permits
+// to check the placement of the photodiodes and the gain of the microphones before a run,
+// without doing a capture.
+//
+// Usage:
+//   -p string
+//         device (serial port name) (default "/dev/ttyUSB0")
+//   -b int
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
+//   -d duration
+//         stop after this duration (default: run until Ctrl-C)
+//   -v
+//         Verbose mode
+//   -D
+//         Debug mode (log the serial protocol)
+//   -V
+//         Display version
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
+)
+
+// Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
+var (
+	Version string
+	Build   string
+)
+
+var (
+	PortAddress = "/dev/ttyUSB0"
+	Baudrate    = 115200
+)
+
+// displayedLines are the input lines, in the order of the display.
+var displayedLines = []string{
+	"Keypad1", "Keypad2", "Keypad3", "Keypad4",
+	"Opto1", "Opto2", "Opto3", "Opto4",
+	"TTLin1", "TTLin2",
+	"Mic1", "Mic2",
+}
+
+func main() {
+	portPtr := flag.String("p", PortAddress, "device (serial port name)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	durationPtr := flag.Duration("d", 0, "stop after this duration (default: run until Ctrl-C)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
+	versionPtr := flag.Bool("V", false, "Display version")
+
+	flag.Parse()
+
+	if *versionPtr {
+		buildinfo.Print(Version, Build)
+		os.Exit(0)
+	}
+
+	// Initialisation
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer b.Disconnect()
+
+	err = b.ResetSerialBuffers()
+	if err != nil {
+		log.Printf("ResetSerialIOBuff %v\n", err)
+	}
+
+	// HandShaking
+	if err = b.Connect(); err != nil {
+		log.Fatalf("Connect returned: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *durationPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *durationPtr)
+		defer cancel()
+	}
+
+	monitor, err := b.MonitorInputs(ctx)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Println("Monitoring the input lines. Press Ctrl-C to quit.")
+	fmt.Println(strings.Join(displayedLines, " "))
+	for s := range monitor.States() {
+		fmt.Print("\r" + formatState(s.Lines))
+	}
+	fmt.Println()
+
+	if err := monitor.Wait(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// formatState shows each line as '#' when active and '.' otherwise, under its name.
+func formatState(state bbtkv3.LineMask) string {
+	cols := make([]string, len(displayedLines))
+	for i, name := range displayedLines {
+		mark := "."
+		if l, _ := bbtkv3.LineByName(name); state.Has(l) {
+			mark = "#"
+		}
+		cols[i] = fmt.Sprintf("%-*s", len(name), mark)
+	}
+	return strings.Join(cols, " ")
+}
@@ -0,0 +1,114 @@
+// Set the output lines of a BlackBoxToolKit
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package main provides bbtk-outputs, a command-line tool which activates output lines of the
+// BBTK by name (ActClose1-4, TTLout1-2, Sounder1-2), e.g. to check the wiring to response boxes
+// or EEG amplifiers before a session:
+//
+//	$ bbtk-outputs -p COM4 TTLout1 ActClose2
+//
+// The lines given as arguments are activated, the others deactivated; after the time set with -t,
+// all lines are deactivated (with -t 0, they are left as set). Without arguments, all lines are
+// deactivated.
+//
+// Usage:
+//   -p string
+//         device (serial port name) (default "/dev/ttyUSB0")
+//   -b int
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
+//   -t duration
+//         how long to keep the lines active (0: leave them active) (default 1s)
+//   -v
+//         Verbose mode
+//   -D
+//         Debug mode (log the serial protocol)
+//   -V
+//         Display version
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
+)
+
+// Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
+var (
+	Version string
+	Build   string
+)
+
+var (
+	PortAddress = "/dev/ttyUSB0"
+	Baudrate    = 115200
+)
+
+func myUsage() {
+	fmt.Printf("Usage: %s [OPTIONS] [line ...]\n", os.Args[0])
+	fmt.Printf("Where each line is one of %s\n", strings.Join(bbtkv3.OutputPortNames, ", "))
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = myUsage
+	portPtr := flag.String("p", PortAddress, "device (serial port name)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	holdPtr := flag.Duration("t", time.Second, "how long to keep the lines active (0: leave them active)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
+	versionPtr := flag.Bool("V", false, "Display version")
+
+	flag.Parse()
+
+	if *versionPtr {
+		buildinfo.Print(Version, Build)
+		os.Exit(0)
+	}
+
+	mask, err := bbtkv3.OutputMaskFromNames(flag.Args()...)
+	if err != nil {
+		myUsage()
+		log.Fatalln(err)
+	}
+
+	// Initialisation
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer b.Disconnect()
+
+	err = b.ResetSerialBuffers()
+	if err != nil {
+		log.Printf("ResetSerialIOBuff %v\n", err)
+	}
+
+	// HandShaking
+	if err = b.Connect(); err != nil {
+		log.Fatalf("Connect returned: %v\n", err)
+	}
+
+	fmt.Printf("Activating output lines: %s\n", mask.Describe())
+	if err = b.SetOutputs(mask); err != nil {
+		log.Fatalln(err)
+	}
+
+	if mask == 0 || *holdPtr <= 0 {
+		return
+	}
+
+	time.Sleep(*holdPtr)
+	fmt.Println("Deactivating all output lines")
+	if err = b.SetOutputs(0); err != nil {
+		log.Fatalln(err)
+	}
+}
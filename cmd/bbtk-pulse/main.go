@@ -0,0 +1,143 @@
+// Generate pulse trains on the output lines of a BlackBoxToolKit
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package main provides bbtk-pulse, a command-line tool which uploads a pulse-train program
+// to the BBTK and runs it, to feed TTL triggers and sounds into an experiment PC, e.g.
+// for end-to-end timing tests:
+//
+//	$ bbtk-pulse -p COM4 program.toml
+//
+// The program is read from a CSV file, with one step per row:
+//
+//	delay,duration,lines,repeat
+//	0,10ms,TTLout1+Sounder1,1
+//	0,990ms,,1
+//
+// or from a TOML file:
+//
+//	time_limit = "10s"
+//
+//	[[step]]
+//	duration = "10ms"
+//	lines = ["TTLout1", "Sounder1"]
+//
+//	[[step]]
+//	duration = "990ms"
+//
+// The steps are played in a loop until the time limit (given in the file or with -t) has elapsed,
+// or until Ctrl-C is pressed.
+//
+// Usage:
+//   -p string
+//         device (serial port name) (default "/dev/ttyUSB0")
+//   -b int
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
+//   -t duration
+//         time limit of the program (overrides the one of the file)
+//   -n
+//         check the program, without running it
+//   -v
+//         Verbose mode
+//   -D
+//         Debug mode (log the serial protocol)
+//   -V
+//         Display version
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
+)
+
+// Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
+var (
+	Version string
+	Build   string
+)
+
+var (
+	PortAddress = "/dev/ttyUSB0"
+	Baudrate    = 115200
+)
+
+func myUsage() {
+	fmt.Printf("Usage: %s [OPTIONS] program.csv|program.toml\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = myUsage
+	portPtr := flag.String("p", PortAddress, "device (serial port name)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	limitPtr := flag.Duration("t", 0, "time limit of the program (overrides the one of the file)")
+	checkPtr := flag.Bool("n", false, "check the program, without running it")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
+	versionPtr := flag.Bool("V", false, "Display version")
+
+	flag.Parse()
+
+	if *versionPtr {
+		buildinfo.Print(Version, Build)
+		os.Exit(0)
+	}
+
+	if flag.NArg() != 1 {
+		myUsage()
+		os.Exit(1)
+	}
+
+	program, err := loadProgram(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *limitPtr > 0 {
+		program.Limit(*limitPtr)
+	}
+	if err := program.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Program: %d steps, lasting %v, time limit %v\n", len(program.Steps), program.Length(), program.TimeLimit)
+	if *checkPtr {
+		for _, s := range program.Steps {
+			fmt.Printf("  after %v, %s for %v\n", s.Delay, s.Mask.Describe(), s.Duration)
+		}
+		return
+	}
+
+	// Initialisation
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer b.Disconnect()
+
+	err = b.ResetSerialBuffers()
+	if err != nil {
+		log.Printf("ResetSerialIOBuff %v\n", err)
+	}
+
+	// HandShaking
+	if err = b.Connect(); err != nil {
+		log.Fatalf("Connect returned: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Println("Running the program. Press Ctrl-C to stop.")
+	if err = b.RunPulseProgram(ctx, program); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println("Done")
+}
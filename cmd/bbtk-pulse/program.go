@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/chrplr/bbtkv3"
+)
+
+// loadProgram reads a pulse-train program from a .csv or a .toml file.
+func loadProgram(filename string) (*bbtkv3.PulseProgram, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p *bbtkv3.PulseProgram
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		p, err = readCSVProgram(f)
+	case ".toml":
+		p, err = readTOMLProgram(f)
+	default:
+		return nil, fmt.Errorf("%s: unknown format (expected .csv or .toml)", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return p, nil
+}
+
+// readCSVProgram reads a program with one step per row:
+//
+//	delay,duration,lines[,repeat]
+//
+// where times are either durations (e.g. "10ms") or integers in µs, lines is either an
+// 8-bit mask (e.g. "00000100") or names of output lines joined by '+' (e.g. "TTLout1+Sounder1"),
+// or empty, and repeat, 1 by default, is the number of times the step is played in a row.
+// A first row starting with "delay" is taken as a header.
+func readCSVProgram(r io.Reader) (*bbtkv3.PulseProgram, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(bbtkv3.PulseProgram)
+	for i, rec := range records {
+		if i == 0 && strings.EqualFold(rec[0], "delay") {
+			continue
+		}
+		if len(rec) != 3 && len(rec) != 4 {
+			return nil, fmt.Errorf("row %d: expected delay,duration,lines[,repeat]", i+1)
+		}
+
+		delay, err := parseTime(rec[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		duration, err := parseTime(rec[1])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		mask, err := parseLines(rec[2])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		repeat := 1
+		if len(rec) == 4 {
+			if repeat, err = strconv.Atoi(rec[3]); err != nil || repeat < 1 {
+				return nil, fmt.Errorf("row %d: invalid repeat count %q", i+1, rec[3])
+			}
+		}
+
+		p.Repeat(repeat, bbtkv3.PulseStep{Delay: delay, Duration: duration, Mask: mask})
+	}
+	return p, nil
+}
+
+// tomlProgram is the layout of the TOML program files:
+//
+//	time_limit = "10s"      # optional
+//
+//	[[step]]
+//	delay = "0s"            # optional
+//	duration = "10ms"
+//	lines = ["TTLout1", "Sounder1"]
+//	repeat = 1              # optional
+type tomlProgram struct {
+	TimeLimit string     `toml:"time_limit"`
+	Steps     []tomlStep `toml:"step"`
+}
+
+type tomlStep struct {
+	Delay    string   `toml:"delay"`
+	Duration string   `toml:"duration"`
+	Lines    []string `toml:"lines"`
+	Repeat   int      `toml:"repeat"`
+}
+
+func readTOMLProgram(r io.Reader) (*bbtkv3.PulseProgram, error) {
+	var tp tomlProgram
+	if _, err := toml.NewDecoder(r).Decode(&tp); err != nil {
+		return nil, err
+	}
+
+	p := new(bbtkv3.PulseProgram)
+	if tp.TimeLimit != "" {
+		limit, err := parseTime(tp.TimeLimit)
+		if err != nil {
+			return nil, fmt.Errorf("time_limit: %w", err)
+		}
+		p.Limit(limit)
+	}
+
+	for i, s := range tp.Steps {
+		var delay time.Duration
+		var err error
+		if s.Delay != "" {
+			if delay, err = parseTime(s.Delay); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		duration, err := parseTime(s.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		mask, err := bbtkv3.OutputMaskFromNames(s.Lines...)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+		p.Repeat(max(s.Repeat, 1), bbtkv3.PulseStep{Delay: delay, Duration: duration, Mask: mask})
+	}
+	return p, nil
+}
+
+// parseTime parses a duration such as "10ms", or an integer number of µs.
+func parseTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if us, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(us) * time.Microsecond, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseLines parses an 8-bit mask or names of output lines joined by '+'.
+func parseLines(s string) (bbtkv3.OutputMask, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if len(s) == 8 && strings.Trim(s, "01") == "" {
+		return bbtkv3.OutputMaskFromString(s)
+	}
+	return bbtkv3.OutputMaskFromNames(strings.Split(s, "+")...)
+}
@@ -0,0 +1,155 @@
+// Make a BlackBoxToolKit act as a participant
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package main provides bbtk-robot, a command-line tool which makes the BBTK respond to stimuli
+// like a participant: each time an input line becomes active (e.g. Opto1, a photodiode on the
+// screen), it activates an output line (e.g. ActClose1, wired to a key of a keyboard or a button box)
+// after a given latency. This permits to validate the recording of reaction times by
+// experiment software:
+//
+//	$ bbtk-robot -p COM4 -i Opto1 -o ActClose1 -l 250ms-350ms -n 20
+//	$ bbtk-robot -p COM4 -n 20 -compare experiment-log.csv -col rt
+//
+// The latency (-l) is either fixed ("300ms"), uniform between two values ("250ms-350ms"),
+// or normal ("normal:300ms,20ms"). The responses are saved to a CSV file; with -compare,
+// the reaction times logged by the experiment (in ms, in the column given by -col) are
+// compared with the latencies commanded.
+//
+// Usage:
+//   -p string
+//         device (serial port name) (default "/dev/ttyUSB0")
+//   -b int
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
+//   -i string
+//         input line watched (default "Opto1")
+//   -o string
+//         output lines activated, joined by '+' (default "ActClose1")
+//   -l string
+//         latency of the responses (default "300ms")
+//   -hold duration
+//         how long the output lines stay active (default 100ms)
+//   -n int
+//         number of responses (0: until Ctrl-C)
+//   -csv string
+//         output file for the responses (default "bbtk-robot.csv")
+//   -compare string
+//         CSV file with the reaction times logged by the experiment
+//   -col string
+//         column of the reaction times (in ms) in the -compare file (default "rt")
+//   -v
+//         Verbose mode
+//   -D
+//         Debug mode (log the serial protocol)
+//   -V
+//         Display version
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
+)
+
+// Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
+var (
+	Version string
+	Build   string
+)
+
+var (
+	PortAddress = "/dev/ttyUSB0"
+	Baudrate    = 115200
+)
+
+func main() {
+	portPtr := flag.String("p", PortAddress, "device (serial port name)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	inputPtr := flag.String("i", "Opto1", "input line watched")
+	outputPtr := flag.String("o", "ActClose1", "output lines activated, joined by '+'")
+	latencyPtr := flag.String("l", "300ms", "latency of the responses: fixed (300ms), uniform (250ms-350ms) or normal (normal:300ms,20ms)")
+	holdPtr := flag.Duration("hold", 100*time.Millisecond, "how long the output lines stay active")
+	trialsPtr := flag.Int("n", 0, "number of responses (0: until Ctrl-C)")
+	csvPtr := flag.String("csv", "bbtk-robot.csv", "output file for the responses")
+	comparePtr := flag.String("compare", "", "CSV file with the reaction times logged by the experiment")
+	colPtr := flag.String("col", "rt", "column of the reaction times (in ms) in the -compare file")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
+	versionPtr := flag.Bool("V", false, "Display version")
+
+	flag.Parse()
+
+	if *versionPtr {
+		buildinfo.Print(Version, Build)
+		os.Exit(0)
+	}
+
+	latency, err := parseLatency(*latencyPtr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	output, err := bbtkv3.OutputMaskFromNames(strings.Split(*outputPtr, "+")...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Initialisation
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer b.Disconnect()
+
+	err = b.ResetSerialBuffers()
+	if err != nil {
+		log.Printf("ResetSerialIOBuff %v\n", err)
+	}
+
+	// HandShaking
+	if err = b.Connect(); err != nil {
+		log.Fatalf("Connect returned: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	robot := bbtkv3.Robot{
+		Input:   *inputPtr,
+		Output:  output,
+		Latency: latency,
+		Hold:    *holdPtr,
+		Trials:  *trialsPtr,
+		OnResponse: func(r bbtkv3.RobotResponse) {
+			fmt.Printf("trial %d: commanded %v, given after %v\n", r.Trial, r.Commanded, r.Actual)
+		},
+	}
+
+	fmt.Printf("Responding with %s to %s. Press Ctrl-C to stop.\n", output.Describe(), *inputPtr)
+	responses, err := b.RunRobot(ctx, robot)
+	if err != nil {
+		log.Println(err)
+	}
+
+	if err := saveResponses(responses, *csvPtr); err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("Responses saved to %s\n", *csvPtr)
+
+	if *comparePtr != "" {
+		logged, err := loadRTs(*comparePtr, *colPtr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		compare(os.Stdout, responses, logged)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+)
+
+// parseLatency parses a latency: fixed ("300ms"), uniform ("250ms-350ms") or normal ("normal:300ms,20ms").
+func parseLatency(s string) (bbtkv3.Latency, error) {
+	if rest, ok := strings.CutPrefix(s, "normal:"); ok {
+		mean, sd, ok := strings.Cut(rest, ",")
+		if !ok {
+			return nil, fmt.Errorf("invalid latency %q: expected normal:mean,sd", s)
+		}
+		m, err1 := time.ParseDuration(mean)
+		d, err2 := time.ParseDuration(sd)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid latency %q", s)
+		}
+		return bbtkv3.NormalLatency{Mean: m, SD: d}, nil
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		l, err1 := time.ParseDuration(lo)
+		h, err2 := time.ParseDuration(hi)
+		if err1 != nil || err2 != nil || h < l {
+			return nil, fmt.Errorf("invalid latency %q", s)
+		}
+		return bbtkv3.UniformLatency{Min: l, Max: h}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latency %q: %w", s, err)
+	}
+	return bbtkv3.FixedLatency(d), nil
+}
+
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// saveResponses saves the responses of the robot to a CSV file.
+func saveResponses(responses []bbtkv3.RobotResponse, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Trial", "Stimulus", "Commanded", "Actual"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+	for _, r := range responses {
+		row := []string{
+			strconv.Itoa(r.Trial),
+			r.Stimulus.Format(time.RFC3339Nano),
+			ms(r.Commanded),
+			ms(r.Actual),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("error writing row: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadRTs reads the reaction times (in ms) in the column col of a CSV file with a header.
+func loadRTs(filename string, col string) ([]time.Duration, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: empty file", filename)
+	}
+	i := slices.Index(records[0], col)
+	if i < 0 {
+		return nil, fmt.Errorf("%s: no column %q", filename, col)
+	}
+
+	var rts []time.Duration
+	for n, rec := range records[1:] {
+		if i >= len(rec) {
+			return nil, fmt.Errorf("%s: row %d: missing column %q", filename, n+2, col)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(rec[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: row %d: %w", filename, n+2, err)
+		}
+		rts = append(rts, time.Duration(v*float64(time.Millisecond)))
+	}
+	return rts, nil
+}
+
+// compare prints, trial by trial, the latencies commanded and the reaction times logged by
+// the experiment, and the mean and standard deviation of their differences.
+func compare(w io.Writer, responses []bbtkv3.RobotResponse, logged []time.Duration) {
+	if len(logged) != len(responses) {
+		fmt.Fprintf(w, "Warning: %d responses given, %d reaction times logged\n", len(responses), len(logged))
+	}
+
+	fmt.Fprintln(w, "Trial\tCommanded\tGiven\tLogged\tLogged-Commanded (ms)")
+	var diffs []float64
+	for i, r := range responses {
+		if i >= len(logged) {
+			break
+		}
+		d := logged[i] - r.Commanded
+		diffs = append(diffs, float64(d)/float64(time.Millisecond))
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", r.Trial, ms(r.Commanded), ms(r.Actual), ms(logged[i]), ms(d))
+	}
+	if len(diffs) == 0 {
+		return
+	}
+
+	var sum, sum2 float64
+	for _, d := range diffs {
+		sum += d
+	}
+	mean := sum / float64(len(diffs))
+	for _, d := range diffs {
+		sum2 += (d - mean) * (d - mean)
+	}
+	sd := 0.0
+	if len(diffs) > 1 {
+		sd = math.Sqrt(sum2 / float64(len(diffs)-1))
+	}
+	fmt.Fprintf(w, "Logged - commanded: mean %.3f ms, sd %.3f ms (%d trials)\n", mean, sd, len(diffs))
+}
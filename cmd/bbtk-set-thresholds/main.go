@@ -13,20 +13,20 @@
 //   -p string
 //         device (serial port name) (default "/dev/ttyUSB0")
 //   -b int
-//         baudrate (speed in bps) (default 115200)
+//         baudrate (speed in bps, 0 to detect it) (default 115200)
 //   -d int
 //         duration of capture (in s) (default 30)
 //   -o string
 //         output file name for captured data (default "bbtk-capture.dat")
+//   -v
+//         Verbose mode
 //   -D
-//         Debug mode (default false)
+//         Debug mode (log the serial protocol)
 //   -V
 //         Display version
 
 // TODO: implement adjustable thresholds, reading the thresholds form the command line or from a configuration file
 // TODO: better handle errors
-// TODO: The way I handle DEBUG is a disaster, implement verbose and debug with 2 level logs.
-//        THe module bbtkv3 uses the env at
 
 package main
 
@@ -35,9 +35,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"time"
 
 	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
 )
 
 // Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
@@ -69,13 +69,15 @@ func myUsage() {
 func main() {
 	flag.Usage = myUsage
 	portPtr := flag.String("p", PortAddress, "device (serial port name)")
-	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps)")
+	speedPtr := flag.Int("b", Baudrate, "baudrate (speed in bps, 0 to detect it)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the serial protocol)")
 	versionPtr := flag.Bool("V", false, "Display version")
 
 	flag.Parse()
 
 	if *versionPtr {
-		fmt.Printf("Version: %s  Build: %s\n", Version, Build[:8])
+		buildinfo.Print(Version, Build)
 		os.Exit(0)
 	}
 
@@ -93,15 +95,13 @@ func main() {
 	}
 
 	// Initialisation
-	verbose := true
-	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, verbose)
+	logger := bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+	b, err := bbtkv3.NewBbtkv3(*portPtr, *speedPtr, logger)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer b.Disconnect()
 
-	time.Sleep(time.Second)
-
 	err = b.ResetSerialBuffers()
 	if err != nil {
 		log.Printf("ResetSerialIOBuff %v\n", err)
@@ -111,7 +111,6 @@ func main() {
 	if err = b.Connect(); err != nil {
 		log.Fatalf("Connect returned: %v\n", err)
 	}
-	time.Sleep(time.Second)
 
 	err = b.ResetSerialBuffers()
 	if err != nil {
@@ -128,14 +127,23 @@ func main() {
 			fmt.Println("bbtkv3 not responding to ECHO")
 		}
 	}
-	time.Sleep(time.Second)
 
 	fmt.Println("Getting current thresholds...")
-	fmt.Printf("%+v\n", b.GetThresholds())
+	current, err := b.GetThresholds()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%+v\n", current)
 
 	fmt.Printf("Setting new thresholds...: %s\n", t.ToString())
-	b.SetThresholds(t)
+	if err = b.SetThresholds(t); err != nil {
+		log.Fatalln(err)
+	}
 
 	fmt.Println("Getting new thresholds...")
-	fmt.Printf("%+v\n", b.GetThresholds())
+	current, err = b.GetThresholds()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("%+v\n", current)
 }
@@ -0,0 +1,89 @@
+// Simulate a BlackBoxToolKit on a virtual serial port
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package main provides bbtk-sim, a command-line tool which creates a pseudo-terminal (Linux only)
+// behaving like the serial port of a BBTKv3. The other tools (bbtk-capture, bbtk-get-thresholds,
+// bbtk-detect-port...) and python/bbtkv2.py can then be run against it, e.g.:
+//
+//	$ bbtk-sim -s scenario.txt
+//	Simulated BBTK available at /dev/pts/5
+//	$ bbtk-capture -p /dev/pts/5 -d 10
+//
+// The scenario file describes what the input lines do during a capture (see sim.ParseScenario).
+//
+// Usage:
+//   -s string
+//         scenario file
+//   -f string
+//         firmware version reported by FIRM
+//   -x float
+//         time scale of captures (2 runs twice as fast as real time) (default 1)
+//   -v
+//         Verbose mode
+//   -D
+//         Debug mode (log the commands received)
+//   -V
+//         Display version
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/internal/buildinfo"
+	"github.com/chrplr/bbtkv3/sim"
+)
+
+// Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
+var (
+	Version string
+	Build   string
+)
+
+func main() {
+	scenarioPtr := flag.String("s", "", "scenario file")
+	firmwarePtr := flag.String("f", sim.DefaultFirmware, "firmware version reported by FIRM")
+	timeScalePtr := flag.Float64("x", 1, "time scale of captures (2 runs twice as fast as real time)")
+	verbosePtr := flag.Bool("v", false, "Verbose mode")
+	debugPtr := flag.Bool("D", false, "Debug mode (log the commands received)")
+	versionPtr := flag.Bool("V", false, "Display version")
+
+	flag.Parse()
+
+	if *versionPtr {
+		buildinfo.Print(Version, Build)
+		os.Exit(0)
+	}
+
+	var scenario sim.Scenario
+	if *scenarioPtr != "" {
+		var err error
+		if scenario, err = sim.LoadScenario(*scenarioPtr); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	dev := sim.New(scenario)
+	dev.Firmware = *firmwarePtr
+	dev.TimeScale = *timeScalePtr
+	dev.Logger = bbtkv3.NewLogger(os.Stderr, *verbosePtr, *debugPtr)
+
+	pty, err := dev.ServePTY()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer pty.Close()
+
+	fmt.Printf("Simulated BBTK available at %s\n", pty.Path)
+	fmt.Println("Press Ctrl-C to quit.")
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	<-c
+}
@@ -6,16 +6,17 @@ package bbtkv3
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"strconv"
+	"log/slog"
 
 	"os"
 	//"path/filepath"
 	"strings"
+	"sync"
 	"time"
-
-	"go.bug.st/serial"
 )
 
 // Variables to be passed on the compilation command line with "-X main.Version=${VERSION} -X main.Build=${BUILD}"
@@ -24,25 +25,58 @@ var (
 	Build   string
 )
 
-// default parameters
+// Timeouts of the exchanges with the BBTK: ResponseTimeout is how long to wait for the
+// answer to an ordinary command, ConnectTimeout how long Connect keeps knocking
+// at the door (the box may still be waking up when the port has just been opened).
 var (
-	verbose = false
-	DEBUG   = false
+	ResponseTimeout = 2 * time.Second
+	ConnectTimeout  = 5 * time.Second
 )
 
-type bbtkv3 struct {
-	port   serial.Port
+// ArgumentDelay is left between the lines of multi-line commands (e.g. SEPV followed by
+// its 8 values), as the firmware may miss a line arriving while it is still processing
+// the previous one. This is the only fixed delay in the protocol.
+var ArgumentDelay = 20 * time.Millisecond
+
+// pollInterval is the read timeout of the transport, i.e. how often the functions
+// waiting for the BBTK check their deadline (or their context).
+const pollInterval = 100 * time.Millisecond
+
+// connectRetryInterval is how long Connect waits for an answer before sending CONN again.
+const connectRetryInterval = 500 * time.Millisecond
+
+// Bbtkv3 drives a BBTK connected through a Transport (normally a serial port).
+// It implements Device.
+//
+// A Bbtkv3 is safe for concurrent use: each method runs its exchange with the BBTK (a command
+// and the reading of its answer) as a transaction, during which the other goroutines wait.
+// Captures, input checks and pulse programs run by RunPulseProgram are transactions lasting
+// until their end, as the BBTK would not answer, or would be interrupted by, other commands.
+type Bbtkv3 struct {
+	mu sync.Mutex // held during the transactions; guards all the fields below
+
+	port   *link
 	reader *bufio.Reader
-}
+	logger *slog.Logger
 
-// init checks if the DEBUG environment variable is set.
-func init() {
-	if _, ok := os.LookupEnv("DEBUG"); ok {
-		DEBUG = true
-		log.Println("DEBUG mode enabled.")
-	} else {
-		DEBUG = false
-	}
+	firmware FirmwareVersion
+	caps     Capabilities
+
+	// identity of the serial port, to find the BBTK again after it was lost
+	portAddress  string
+	baudrate     int
+	serialNumber string
+
+	// last settings, restored after a reconnection
+	thresholds *Thresholds
+	smoothing  *SmoothingMask
+
+	smoothingLatency *time.Duration // overrides Capabilities.SmoothingLatency if not nil
+
+	autoReconnect   bool
+	reconnecting    bool
+	onReconnect     func(ReconnectEvent)
+	reconnectEvents []ReconnectEvent // not yet delivered to onReconnect (see unlock)
 }
 
 func GetPortFromEnv() string {
@@ -53,84 +87,152 @@ func GetPortFromEnv() string {
 	}
 }
 
-// NewBbtkv3 creates a new bbtkv3 object, connecting to the serial device at portAddress.
-func NewBbtkv3(portAddress string, baudrate int, verbose_flag bool) (*bbtkv3, error) {
-	var box bbtkv3
-
-	verbose = verbose_flag
-
-	mode := &serial.Mode{
-		BaudRate: baudrate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit,
+// NewBbtkv3 creates a new Bbtkv3 object, connecting to the serial device at portAddress.
+// If baudrate is AutoBaudrate, the rates of KnownBaudrates are tried until the BBTK answers
+// (see DetectBaudrate). Messages are logged to logger, which may be nil.
+func NewBbtkv3(portAddress string, baudrate int, logger *slog.Logger) (*Bbtkv3, error) {
+	if logger == nil {
+		logger = discardLogger
 	}
 
-	if verbose {
-		fmt.Printf("Trying to open %v at %d bps...\n", portAddress, baudrate)
+	if baudrate == AutoBaudrate {
+		return DetectBaudrate(context.Background(), portAddress, logger)
 	}
 
-	port, err := serial.Open(portAddress, mode)
-	if err != nil {
-		return nil, fmt.Errorf("error while trying to open %s (at %d bps): %w (Under Linux, try `sudo modprobe ftdi_sio`)", portAddress, baudrate, err)
-	}
+	logger.Info("opening serial port", "port", portAddress, "baudrate", baudrate)
 
-	if verbose {
-		fmt.Println("ok!")
+	port, err := OpenSerial(portAddress, baudrate)
+	if err != nil {
+		return nil, err
 	}
 
-	port.SetReadTimeout(time.Second)
 	// port.SetDTR(false)
 	// port.SetRTS(false)
 
-	box.port = port
-	box.reader = bufio.NewReader(port)
+	b := NewBbtkv3FromTransport(port, logger.With("port", portAddress))
+	b.portAddress = portAddress
+	b.baudrate = baudrate
+	b.serialNumber = usbSerialNumber(portAddress)
 
-	return &box, nil
+	return b, nil
 }
 
-// Connect initiates a connection to the BBTK.
-func (b bbtkv3) Connect() error {
+// NewBbtkv3FromTransport creates a new Bbtkv3 object talking to the BBTK over t.
+// This permits to drive the box through something else than a local serial port
+// (a pipe, a pty, a TCP socket, a simulator...). Messages are logged to logger, which may be nil.
+func NewBbtkv3FromTransport(t Transport, logger *slog.Logger) *Bbtkv3 {
+	var box Bbtkv3
 
-	if verbose {
-		fmt.Println("Trying to connect to BBTK...")
+	if logger == nil {
+		logger = discardLogger
 	}
 
-	b.SendCommand("CONN")
+	box.logger = logger
+	box.caps = CapabilitiesOf(ModelUnknown)
+	box.attach(t)
 
-	time.Sleep(100. * time.Millisecond)
+	return &box
+}
 
-	resp, err := b.ReadLine()
+// attach makes b talk to the BBTK over t.
+func (b *Bbtkv3) attach(t Transport) {
+	t.SetReadTimeout(pollInterval)
+
+	b.port = &link{t: t, opened: time.Now()}
+	b.reader = bufio.NewReader(b.port)
+}
+
+// Connect initiates a connection to the BBTK, and identifies its model (see Identify).
+func (b *Bbtkv3) Connect() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.connect(time.Now().Add(ConnectTimeout)); err != nil {
+		return fmt.Errorf("Connect: %w", err)
+	}
+
+	v, err := b.identify()
+	if v.Raw == "" {
+		return fmt.Errorf("Connect: %w", err)
+	}
 	if err != nil {
-		return err
+		b.logger.Warn("unknown model, assuming the capabilities of a BBTKv3", "firmware", v.Raw, "err", err)
 	}
-	if resp != "BBTK;" {
-		return fmt.Errorf("Connect: expected \"BBTK;\", got \"%v\"", resp)
+	return nil
+}
+
+// connect sends CONN until the BBTK answers or deadline is reached.
+func (b *Bbtkv3) connect(deadline time.Time) error {
+	b.logger.Info("connecting to the BBTK")
+
+	for attempt := 1; ; attempt++ {
+		if err := b.send("CONN"); err != nil {
+			return err
+		}
+
+		resp, err := b.readLineBefore(earliest(deadline, time.Now().Add(connectRetryInterval)))
+		if errors.Is(err, ErrTimeout) && time.Now().Before(deadline) {
+			b.logger.Debug("no answer to CONN, retrying", "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if resp != "BBTK;" {
+			return &UnexpectedResponseError{Command: "CONN", Expected: "BBTK;", Got: resp}
+		}
+
+		if attempt > 1 {
+			// late answers to the previous attempts may still be on their way
+			if err := b.sync("CONN"); err != nil {
+				return err
+			}
+		}
+
+		b.logger.Info("connected", "attempts", attempt)
+		return nil
 	}
+}
 
-	if verbose {
-		fmt.Println("ok!")
+func earliest(t1, t2 time.Time) time.Time {
+	if t1.Before(t2) {
+		return t1
 	}
-	return nil
+	return t2
 }
 
 // Disconnect closes the connection to the bbtkv3.
-func (b bbtkv3) Disconnect() error {
+// It waits for the end of the transaction in progress, if any.
+func (b *Bbtkv3) Disconnect() error {
+	b.mu.Lock()
+	defer b.unlock()
+
 	//b.SendBreak()
 	return b.port.Close()
 }
 
-// SendBreak send a serial break to the bbtk. Useful on the bbtkv2 when the box is stucked, but HARMFUL on the bbtkv3 !!! So disabled.
-func (b bbtkv3) SendBreak() {
-	//if DEBUG {
-	//	log.Println("Sending serial break.")
-	//}
-	//b.port.Break(10. * time.Millisecond)
-	time.Sleep(time.Second)
+// SendBreak send a serial break to the bbtk. Useful on the bbtkv2 when the box is stucked, but HARMFUL on the bbtkv3 !!!
+// So it fails with ErrUnsupported unless the BBTK was identified as a BBTKv2 (see Capabilities).
+func (b *Bbtkv3) SendBreak() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if !b.caps.BreakAllowed {
+		return &UnsupportedError{Model: b.caps.Model, Feature: "serial break"}
+	}
+	b.logger.Debug("sending serial break")
+	return b.port.Break(BreakDuration)
 }
 
 // ResetSerialBuffers purges the input and output buffers of the serial port.
-func (b bbtkv3) ResetSerialBuffers() error {
+func (b *Bbtkv3) ResetSerialBuffers() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.resetSerialBuffers()
+}
+
+func (b *Bbtkv3) resetSerialBuffers() error {
 	if err := b.port.ResetInputBuffer(); err != nil {
 		return err
 	}
@@ -138,48 +240,128 @@ func (b bbtkv3) ResetSerialBuffers() error {
 	return b.port.ResetOutputBuffer()
 }
 
-// SendCommand adds CRLF to cmd and send it to the BBTK
-func (b bbtkv3) SendCommand(cmd string) error {
+// SendCommand adds CRLF to cmd and send it to the BBTK.
+//
+// SendCommand followed by ReadLine is not a transaction: another goroutine may read the answer
+// in between. Prefer the methods dedicated to the commands (IsAlive, GetThresholds...).
+func (b *Bbtkv3) SendCommand(cmd string) error {
+	b.mu.Lock()
+	defer b.unlock()
 
-	if DEBUG {
-		log.Printf("SendCommand: \"%v\"\n", cmd)
-	}
+	return b.send(cmd)
+}
+
+// send is SendCommand, for the callers holding b.mu.
+func (b *Bbtkv3) send(cmd string) error {
+	b.logger.Log(context.Background(), LevelTrace, "send", "cmd", cmd, "t", time.Since(b.port.opened))
 
 	_, err := b.port.Write([]byte(cmd + "\r\n"))
 
-	time.Sleep(50. * time.Millisecond)
+	return b.checkLost(err)
+}
 
-	return err
+// sendWithArgs sends cmd followed by its arguments, one per line, leaving ArgumentDelay
+// between the lines.
+func (b *Bbtkv3) sendWithArgs(cmd string, args ...string) error {
+	if err := b.send(cmd); err != nil {
+		return err
+	}
+	for _, a := range args {
+		time.Sleep(ArgumentDelay)
+		if err := b.send(a); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// ReadLine returns the next line output by the BBTK
-func (b bbtkv3) ReadLine() (string, error) {
-	var s string
-	var err error
-	if s, err = b.reader.ReadString('\n'); err != nil {
-		return "", fmt.Errorf("in Readline(): %w", err)
+// sync sends ECHO and waits for its answer, discarding the lines received in the meantime.
+// As the BBTK processes the commands in order, this tells that the commands sent before,
+// which do not answer anything (e.g. SMOO), have been taken into account.
+func (b *Bbtkv3) sync(after string) error {
+	if err := b.send("ECHO"); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(ResponseTimeout)
+	for {
+		resp, err := b.readLineBefore(deadline)
+		if err != nil {
+			return fmt.Errorf("waiting for the completion of %s: %w", after, err)
+		}
+		if resp == "ECHO" {
+			return nil
+		}
+		b.logger.Debug("discarding unexpected line", "after", after, "line", resp)
 	}
+}
+
+// ReadLine returns the next line output by the BBTK.
+// If no complete line arrives within ResponseTimeout, it fails with ErrTimeout; the
+// characters already received are kept for the next call.
+func (b *Bbtkv3) ReadLine() (string, error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.readResponse()
+}
 
-	if DEBUG {
-		log.Printf("In Readline(), got \"%s\"\n", s[:len(s)-1])
+// readResponse is ReadLine, for the callers holding b.mu.
+func (b *Bbtkv3) readResponse() (string, error) {
+	return b.readLineBefore(time.Now().Add(ResponseTimeout))
+}
+
+// readLineBefore returns the next line output by the BBTK, failing with ErrTimeout
+// if it is not complete at deadline.
+func (b *Bbtkv3) readLineBefore(deadline time.Time) (string, error) {
+	for {
+		s, err := b.readLine()
+		if errors.Is(err, ErrTimeout) && time.Now().Before(deadline) {
+			continue
+		}
+		return s, err
+	}
+}
+
+// readLine returns the next line output by the BBTK, waiting at most for one read timeout
+// of the transport (pollInterval). If the read buffer fills up without an end of line, its
+// content is dropped and readLine fails with ErrLineTooLong.
+func (b *Bbtkv3) readLine() (string, error) {
+	for {
+		buf, _ := b.reader.Peek(b.reader.Buffered())
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			s := string(buf[:i])
+			b.reader.Discard(i + 1)
+			b.logger.Log(context.Background(), LevelTrace, "recv", "line", s, "t", time.Since(b.port.opened), "wait", time.Since(b.port.lastWrite))
+			return s, nil
+		}
+
+		if _, err := b.reader.Peek(len(buf) + 1); err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				b.reader.Discard(len(buf))
+				return "", fmt.Errorf("in Readline(): %w: no end of line in %d bytes", ErrLineTooLong, len(buf))
+			}
+			return "", fmt.Errorf("in Readline(): %w", b.checkLost(err))
+		}
 	}
-	return s[:len(s)-1], err
 }
 
 // IsAlive sends an 'ECHO' command to the bbtkv3 and expects 'ECHO' in return.
 // This permits to check that the bbtkv3 is up and running.
-func (b bbtkv3) IsAlive() (bool, error) {
+func (b *Bbtkv3) IsAlive() (bool, error) {
+	b.mu.Lock()
+	defer b.unlock()
 
-	if err := b.SendCommand("ECHO"); err != nil {
+	if err := b.send("ECHO"); err != nil {
 		return false, fmt.Errorf("IsAlive: %w", err)
 	} else {
-		resp, err := b.ReadLine()
+		resp, err := b.readResponse()
 		if err != nil {
 			return false, fmt.Errorf("IsAlive: %w", err)
 		}
 
 		if resp != "ECHO" {
-			return false, fmt.Errorf("IsAlive: Expected \"ECHO\", Got \"%v\"", resp)
+			return false, &UnexpectedResponseError{Command: "ECHO", Expected: "ECHO", Got: resp}
 		} else {
 			return true, nil
 		}
@@ -190,12 +372,26 @@ func (b bbtkv3) IsAlive() (bool, error) {
 // SetSmoothing on Opto and Mic sensors.
 // When smoothing is 'off', the BBTK will detect *all* leading edges, e.g.
 // each refresh on a CRT.
-// When smoothing is 'on', you need to subtract 20ms from offset times.
-func (b bbtkv3) SetSmoothing(mask SmoothingMask) error {
-	if err := b.SendCommand("SMOO"); err != nil {
-		return fmt.Errorf("SetSmoothing: %w", err)
-	}
+// When smoothing is 'on', the offsets are detected 20ms late (Capabilities().SmoothingLatency):
+// the captures record the smoothing mask, so that CaptureEventsWithSmoothing corrects them.
+func (b *Bbtkv3) SetSmoothing(mask SmoothingMask) error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.setSmoothing(mask)
+}
+
+// SetSmoothingLatency sets the latency of smoothing recorded in the captures (CaptureResult.Smoothing),
+// instead of the one of the model (Capabilities.SmoothingLatency), e.g. for a firmware behaving
+// differently.
+func (b *Bbtkv3) SetSmoothingLatency(latency time.Duration) {
+	b.mu.Lock()
+	defer b.unlock()
 
+	b.smoothingLatency = &latency
+}
+
+func (b *Bbtkv3) setSmoothing(mask SmoothingMask) error {
 	strMask := ""
 
 	if mask.Mic1 {
@@ -236,56 +432,73 @@ func (b bbtkv3) SetSmoothing(mask SmoothingMask) error {
 
 	strMask += "11"
 
-	err := b.SendCommand(strMask)
-	if err != nil {
+	if err := b.sendWithArgs("SMOO", strMask); err != nil {
 		return fmt.Errorf("SetSmoothing: %w", err)
 	}
+	if err := b.sync("SMOO"); err != nil {
+		return fmt.Errorf("SetSmoothing: %w", err)
+	}
+	b.smoothing = &mask
 	return nil
 }
 
 // FLUS command attempts to clear the USB output buffer.
-// If this fails you may need to send a Serial Break with SendBreak().
-func (b bbtkv3) Flush() error {
-	if err := b.SendCommand("FLUS"); err != nil {
-		return err
+// If this fails (i.e. the BBTK does not answer ECHO afterwards), you may need to send
+// a Serial Break with SendBreak().
+func (b *Bbtkv3) Flush() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.send("FLUS"); err != nil {
+		return fmt.Errorf("Flush: %w", err)
+	}
+	if err := b.sync("FLUS"); err != nil {
+		return fmt.Errorf("Flush: %w", err)
 	}
-	time.Sleep(time.Second)
 	return nil
 }
 
 // Retrieves the version of the BBTK firmware
 // currently running in the ARM chip.
-func (b bbtkv3) GetFirmwareVersion() string {
-	b.SendCommand("FIRM")
-	resp, err := b.ReadLine()
-	if err != nil {
-		fmt.Printf("In GetFirmWareVersion(): %v", err)
-	}
-	return resp
+func (b *Bbtkv3) GetFirmwareVersion() (string, error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.getFirmwareVersion()
 }
 
-func str2uint8(s string) uint8 {
-	num, err := strconv.ParseUint(s, 10, 8)
+func (b *Bbtkv3) getFirmwareVersion() (string, error) {
+	if err := b.send("FIRM"); err != nil {
+		return "", fmt.Errorf("GetFirmwareVersion: %w", err)
+	}
+	resp, err := b.readResponse()
 	if err != nil {
-		log.Fatalf("Error: %v", err)
+		return "", fmt.Errorf("GetFirmwareVersion: %w", err)
 	}
-	return uint8(num)
+	return resp, nil
 }
 
-func (b bbtkv3) GetThresholds() Thresholds {
-	b.SendCommand("GEPV")
-	resp, err := b.ReadLine()
-	if err != nil {
-		fmt.Printf("In GetThresholds(): %v", err)
+// GetThresholds reads the current sensor activation thresholds.
+func (b *Bbtkv3) GetThresholds() (Thresholds, error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.getThresholds()
+}
+
+func (b *Bbtkv3) getThresholds() (Thresholds, error) {
+	if err := b.send("GEPV"); err != nil {
+		return Thresholds{}, fmt.Errorf("GetThresholds: %w", err)
 	}
-	if DEBUG {
-		fmt.Println(resp)
+	resp, err := b.readResponse()
+	if err != nil {
+		return Thresholds{}, fmt.Errorf("GetThresholds: %w", err)
 	}
-	x, err := ThresholdsFromString(resp[:len(resp)-1])
+	x, err := ThresholdsFromString(strings.TrimSuffix(resp, ";"))
 	if err != nil {
-		fmt.Printf("In GetThresholds(): %v", err)
+		return Thresholds{}, fmt.Errorf("%w (%v)", &UnexpectedResponseError{Command: "GEPV", Expected: "8 comma-separated values", Got: resp}, err)
 	}
-	return x
+	return x, nil
 }
 
 // Sets the sensor activation thresholds for the eight
@@ -293,72 +506,110 @@ func (b bbtkv3) GetThresholds() Thresholds {
 // Sounder volume (amplitude) and Opto luminance
 // activation threshold. Activation thresholds range
 // from 0-127.
-func (b bbtkv3) SetThresholds(x Thresholds) {
-	b.SendCommand("SEPV")
-	b.SendCommand(fmt.Sprintf("%d", x.Mic1))
-	b.SendCommand(fmt.Sprintf("%d", x.Mic2))
-	b.SendCommand(fmt.Sprintf("%d", x.Sounder1))
-	b.SendCommand(fmt.Sprintf("%d", x.Sounder2))
-	b.SendCommand(fmt.Sprintf("%d", x.Opto1))
-	b.SendCommand(fmt.Sprintf("%d", x.Opto2))
-	b.SendCommand(fmt.Sprintf("%d", x.Opto3))
-	b.SendCommand(fmt.Sprintf("%d", x.Opto4))
+// The values are read back to check that the BBTK took them.
+func (b *Bbtkv3) SetThresholds(x Thresholds) error {
+	b.mu.Lock()
+	defer b.unlock()
 
-	time.Sleep(1 * time.Second)
+	return b.setThresholds(x)
+}
+
+func (b *Bbtkv3) setThresholds(x Thresholds) error {
+	values := []uint8{x.Mic1, x.Mic2, x.Sounder1, x.Sounder2, x.Opto1, x.Opto2, x.Opto3, x.Opto4}
+
+	args := make([]string, len(values))
+	for i, v := range values {
+		args[i] = fmt.Sprintf("%d", v)
+	}
+	if err := b.sendWithArgs("SEPV", args...); err != nil {
+		return fmt.Errorf("SetThresholds: %w", err)
+	}
+
+	got, err := b.getThresholds()
+	if err != nil {
+		return fmt.Errorf("SetThresholds: %w", err)
+	}
+	if got != x {
+		return &UnexpectedResponseError{Command: "SEPV", Expected: x.ToString(), Got: got.ToString()}
+	}
+	b.thresholds = &x
+	return nil
 }
 
 // AdjustThresholds launches the procedure to manually set up the thresholds on the BBTK
-func (b bbtkv3) AdjustThresholds() {
-	b.SendCommand("AJPV")
-	response, _ := b.ReadLine()
-	for response != "Done;" {
-		if DEBUG {
-			fmt.Printf("Adjusting Threshold: expecting \"DONE;\", got \"%v\"", response)
+// and waits until the user is done.
+func (b *Bbtkv3) AdjustThresholds() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.send("AJPV"); err != nil {
+		return fmt.Errorf("AdjustThresholds: %w", err)
+	}
+	for {
+		response, err := b.readLine()
+		if errors.Is(err, ErrTimeout) { // the user is still busy with the knob
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("AdjustThresholds: %w", err)
+		}
+		if response == "Done;" {
+			return nil
 		}
-		time.Sleep(100. * time.Millisecond)
-		response, _ = b.ReadLine()
+		b.logger.Debug("AdjustThresholds: unexpected response", "expected", "Done;", "got", response)
 	}
 }
 
+// ClearTimingDataTimeout is how long ClearTimingData waits for the BBTK to erase its memory.
+var ClearTimingDataTimeout = time.Minute
+
 // ClearTimingData either formats the whole of the BBTK's internal
 // RAM (on first power up or after a reset) or erases
 // only previously used sectors.
-func (b bbtkv3) ClearTimingData() {
-	b.SendCommand("SPIE")
+func (b *Bbtkv3) ClearTimingData() error {
+	b.mu.Lock()
+	defer b.unlock()
 
-	response, err := b.ReadLine()
-	if err != nil {
-		fmt.Printf("ClearTimingData: %v", err)
-	}
-	if response != "FRMT;" && response != "ESEC;" {
-		fmt.Printf("Warning: ClearTimingData expected \"FRMT;\" or \"ESEC;\", got \"%v\"", response)
+	if err := b.send("SPIE"); err != nil {
+		return fmt.Errorf("ClearTimingData: %w", err)
 	}
 
-	response, err = b.ReadLine()
+	response, err := b.readResponse()
 	if err != nil {
-		log.Fatalf("ClearTimingData @ call ReadLine(): %v", err)
+		return fmt.Errorf("ClearTimingData: %w", err)
+	}
+	if response != "FRMT;" && response != "ESEC;" {
+		return &UnexpectedResponseError{Command: "SPIE", Expected: "FRMT; or ESEC;", Got: response}
 	}
 
-	for response != "DONE;" {
-		if DEBUG {
-			fmt.Printf("Warning: ClearTimingData expected \"DONE;\", got \"%v\"", response)
-		}
-
-		time.Sleep(100. * time.Millisecond)
-		response, err = b.ReadLine()
+	deadline := time.Now().Add(ClearTimingDataTimeout)
+	for {
+		response, err = b.readLineBefore(deadline)
 		if err != nil {
-			log.Fatalf("ClearTimingData: %v", err)
+			return fmt.Errorf("ClearTimingData: %w", err)
+		}
+		if response == "DONE;" {
+			break
 		}
+		b.logger.Debug("ClearTimingData: unexpected response", "expected", "DONE;", "got", response)
 	}
 
-	time.Sleep(time.Second)
+	return nil
 }
 
 // DisplayInfoOnBBTK causes the BBTK to display a copyright notice
 // and release date of the firmware it is running on its LCD screen.
-func (b bbtkv3) DisplayInfoOnBBTK() {
-	b.SendCommand("ABOU")
-	time.Sleep(1. * time.Second)
+func (b *Bbtkv3) DisplayInfoOnBBTK() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.send("ABOU"); err != nil {
+		return fmt.Errorf("DisplayInfoOnBBTK: %w", err)
+	}
+	if err := b.sync("ABOU"); err != nil {
+		return fmt.Errorf("DisplayInfoOnBBTK: %w", err)
+	}
+	return nil
 }
 
 // CaptureEvents captures events for a specified duration.
@@ -368,72 +619,28 @@ func (b bbtkv3) DisplayInfoOnBBTK() {
 //   - duration: The duration in seconds for which events should be captured.
 //
 // Returns:
-//   - A string containing the captured event data.
+//   - A string containing the captured event data, and an error if the capture failed.
 //
 // The function performs the following steps:
 //  1. Sends the "DSCM" command to the device.
 //  2. Sends the "TIML" command to the device.
 //  3. Sends the duration (in microseconds) to the device.
 //  4. Sends the "RUDS" command to the device.
-//  5. Waits for the specified duration minus one second.
-//  6. Reads data from the device until the "EDAT" marker is found.
+//  5. Reads data from the device until the "EDAT" marker is found.
 //
-// If any command fails, an error is logged. If reading from the device fails, the function logs the error and terminates the program.
-func (b bbtkv3) CaptureEvents(duration int) string {
-	var err error
-	time.Sleep(time.Second)
-	err = b.SendCommand("DSCM")
-	if err != nil {
-		log.Printf("CaptureEvents: DSCM %v", err)
-	}
-
-	time.Sleep(time.Second)
-	err = b.SendCommand("TIML")
-	if err != nil {
-		log.Printf("CaptureEvents: TIML %v", err)
-	}
-
-	time.Sleep(time.Second)
-	err = b.SendCommand(fmt.Sprintf("%d", duration*1000000))
-	if err != nil {
-		log.Printf("CaptureEvents: %v", err)
-	}
+// CaptureEvents blocks until the end of the capture; see StartCapture for a non-blocking,
+// cancellable, version.
+func (b *Bbtkv3) CaptureEvents(duration int) (string, error) {
+	var text strings.Builder
 
-	time.Sleep(time.Second)
-	time.Sleep(500 * time.Millisecond)
-	err = b.SendCommand("RUDS")
+	c, err := b.StartCapture(context.Background(), time.Duration(duration)*time.Second, &text)
 	if err != nil {
-		log.Printf("CaptureEvents: RUDS %v", err)
+		return "", fmt.Errorf("CaptureEvents: %w", err)
 	}
 
-	waitingDuration := time.Duration(duration-1) * time.Second
-	// time.Sleep(waitingDuration)
-	for i := int(waitingDuration.Seconds()); i > 0; i-- {
-		fmt.Printf("%d ", i)
-		time.Sleep(1. * time.Second)
+	if _, err := c.Wait(); err != nil {
+		return text.String(), fmt.Errorf("CaptureEvents: %w", err)
 	}
-	fmt.Println()
-
-	if DEBUG {
-		fmt.Println("Waiting for data...")
-	}
-
-	text := ""
-	buff := make([]byte, 1024)
-	for {
-		n, err := b.port.Read(buff)
-		if err != nil {
-			log.Fatal(err)
-			break
-		}
-		if n > 0 {
-			text += string(buff[:n])
-		}
-		if strings.Contains(string(buff), "EDAT") {
-			break
-		}
-	}
-
-	return text
 
+	return text.String(), nil
 }
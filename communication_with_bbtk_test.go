@@ -0,0 +1,25 @@
+package bbtkv3
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadLineTooLong(t *testing.T) {
+	garbage := strings.Repeat("x", 5000)
+	b := NewBbtkv3FromTransport(NewReplayer([]TranscriptEntry{
+		{Op: OpRead, Data: []byte(garbage + "\nECHO\n")},
+	}), nil)
+
+	if _, err := b.ReadLine(); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("got %v, want ErrLineTooLong", err)
+	}
+	// The reading goes on after the bytes dropped.
+	if s, err := b.ReadLine(); err != nil || s != garbage[b.reader.Size():] {
+		t.Errorf("got %d bytes, %v, want the rest of the long line", len(s), err)
+	}
+	if s, err := b.ReadLine(); err != nil || s != "ECHO" {
+		t.Errorf("got %q, %v, want ECHO", s, err)
+	}
+}
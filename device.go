@@ -0,0 +1,53 @@
+package bbtkv3
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Device is the set of operations offered by a BBTK.
+// *Bbtkv3 implements it; programs can depend on Device rather than on *Bbtkv3,
+// so that the box can be replaced by a fake in tests, or wrapped (e.g. to add logging or retries).
+type Device interface {
+	Connect() error
+	Disconnect() error
+	Reconnect(ctx context.Context) error
+	IsAlive() (bool, error)
+	ResetSerialBuffers() error
+	Flush() error
+	SendBreak() error
+
+	GetFirmwareVersion() (string, error)
+	Identify() (FirmwareVersion, error)
+	Capabilities() Capabilities
+	DisplayInfoOnBBTK() error
+
+	GetThresholds() (Thresholds, error)
+	SetThresholds(x Thresholds) error
+	AdjustThresholds() error
+	SetSmoothing(mask SmoothingMask) error
+
+	ClearTimingData() error
+	CaptureEvents(duration int) (string, error)
+	StartCapture(ctx context.Context, duration time.Duration, raw io.Writer) (*CaptureSession, error)
+
+	MonitorInputs(ctx context.Context) (*InputMonitor, error)
+	SetOutputs(mask OutputMask) error
+}
+
+var _ Device = (*Bbtkv3)(nil)
+
+// NewFinishedCaptureSession returns a CaptureSession which has already ended with the given
+// result and error. It is meant for implementations of Device which do not talk to a real BBTK.
+func NewFinishedCaptureSession(result *CaptureResult, err error) *CaptureSession {
+	c := &CaptureSession{
+		progress: make(chan CaptureProgress),
+		done:     make(chan struct{}),
+		result:   result,
+		err:      err,
+	}
+	close(c.progress)
+	close(c.done)
+	return c
+}
@@ -0,0 +1,176 @@
+package bbtkv3
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// FTDIVendorID is the USB vendor ID of the FTDI chip through which the BBTKv3 is connected.
+const FTDIVendorID = "0403"
+
+// DefaultDiscoveryTimeout is the time Discover spends on each port when no timeout is given.
+const DefaultDiscoveryTimeout = 3 * time.Second
+
+// DiscoveryResult is the outcome of the probe of a serial port by Discover.
+type DiscoveryResult struct {
+	Port     string
+	Found    bool   // a BBTK answered on this port
+	Firmware string // answer to FIRM, when a BBTK was found
+	Model    Model  // model of the BBTK, according to its firmware
+	Baudrate int    // rate at which the BBTK answered
+
+	// USB identity of the port, when the OS reports it.
+	IsUSB        bool
+	VID          string
+	PID          string
+	SerialNumber string
+
+	Err error // why no BBTK was found on this port
+}
+
+// IsFTDI tells if the port is provided by an FTDI USB chip, as the BBTKv3's is.
+func (r DiscoveryResult) IsFTDI() bool {
+	return r.IsUSB && strings.EqualFold(r.VID, FTDIVendorID)
+}
+
+// priority orders the ports to report the likeliest ones first: FTDI, then other USB devices.
+func (r DiscoveryResult) priority() int {
+	switch {
+	case r.IsFTDI():
+		return 0
+	case r.IsUSB:
+		return 1
+	}
+	return 2
+}
+
+// Discover looks for BBTKs on the serial ports listed in candidates or, if candidates
+// is empty, on all the serial ports of the computer. The ports are probed concurrently
+// (CONN then FIRM at the given baudrate, or at each of KnownBaudrates if it is AutoBaudrate),
+// each for at most timeout (per rate tried), as some ports may block. The results are sorted with FTDI ports first, then other USB ports.
+//
+// The returned error only reports a failure to list the ports; the outcome of each probe
+// is in the Err field of its result.
+func Discover(ctx context.Context, candidates []string, baudrate int, timeout time.Duration, logger *slog.Logger) ([]DiscoveryResult, error) {
+	if logger == nil {
+		logger = discardLogger
+	}
+	if timeout <= 0 {
+		timeout = DefaultDiscoveryTimeout
+	}
+
+	details, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		if len(candidates) == 0 {
+			return nil, err
+		}
+		logger.Warn("cannot get the details of the serial ports", "err", err)
+	}
+
+	known := make(map[string]*enumerator.PortDetails)
+	for _, d := range details {
+		known[d.Name] = d
+	}
+	if len(candidates) == 0 {
+		for _, d := range details {
+			candidates = append(candidates, d.Name)
+		}
+	}
+
+	results := make([]DiscoveryResult, len(candidates))
+	var wg sync.WaitGroup
+	for i, port := range candidates {
+		results[i].Port = port
+		if d, ok := known[port]; ok {
+			results[i].IsUSB = d.IsUSB
+			results[i].VID = d.VID
+			results[i].PID = d.PID
+			results[i].SerialNumber = d.SerialNumber
+		}
+
+		wg.Add(1)
+		go func(r *DiscoveryResult) {
+			defer wg.Done()
+			r.Firmware, r.Baudrate, r.Err = probe(ctx, r.Port, baudrate, timeout, logger.With("port", r.Port))
+			r.Found = r.Err == nil
+			if r.Found {
+				v, _ := ParseFirmware(r.Firmware)
+				r.Model = v.Model
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].priority() < results[j].priority()
+	})
+
+	return results, nil
+}
+
+// probe checks if a BBTK answers at port and returns its firmware version and the rate it answered at.
+// It gives up after timeout, leaving behind, if need be, a goroutine which closes
+// the port when the blocked call returns.
+func probe(ctx context.Context, port string, baudrate int, timeout time.Duration, logger *slog.Logger) (string, int, error) {
+	if baudrate == AutoBaudrate {
+		timeout *= time.Duration(len(KnownBaudrates))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	type outcome struct {
+		firmware string
+		baudrate int
+		err      error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		var b *Bbtkv3
+		if baudrate == AutoBaudrate {
+			var err error
+			if b, err = detectBaudrate(ctx, port, deadline, logger); err != nil {
+				done <- outcome{err: err}
+				return
+			}
+		} else {
+			t, err := OpenSerial(port, baudrate)
+			if err != nil {
+				done <- outcome{err: err}
+				return
+			}
+			logger.Debug("opened port")
+
+			b = NewBbtkv3FromTransport(t, logger)
+			b.baudrate = baudrate
+			b.ResetSerialBuffers()
+			if err := b.connect(deadline); err != nil {
+				b.Disconnect()
+				done <- outcome{err: err}
+				return
+			}
+		}
+		defer b.Disconnect()
+
+		firmware, err := b.GetFirmwareVersion()
+		done <- outcome{firmware: firmware, baudrate: b.Baudrate(), err: err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.firmware, o.baudrate, o.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", 0, fmt.Errorf("%w: no answer within %v", ErrTimeout, timeout)
+		}
+		return "", 0, ctx.Err()
+	}
+}
@@ -0,0 +1,206 @@
+package bbtkv3
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DSCMReader parses, as it arrives, the data sent by the BBTK at the end of a capture in DSCM mode:
+//
+//	SDAT;
+//	3;          number of events
+//	30000000;   duration of the capture (in µs)
+//	120000;     number of samples
+//	11001100110001010101000000123456;
+//	01001100110001010101000000234567;
+//	11001100110001010101000000345678;
+//	EDAT;
+//
+// Only the current record is held in memory, so that arbitrarily long captures can be processed.
+//
+// The data are checked as they are read: the header must be complete, the records well formed
+// and in chronological order, and their number must be the one announced by the header. A damaged
+// download thus ends with an error rather than with missing events. The records stamped after the
+// end of the capture (the duration of the header) are accepted, as the last transitions may be
+// stamped at or just after it, but counted (see Late).
+type DSCMReader struct {
+	r    *bufio.Reader
+	line int // line of the input being read, from 1
+
+	header     DSCMHeader
+	headerRead bool
+	count      int   // records read
+	late       int   // records stamped after the end of the capture
+	last       int64 // timestamp of the last record
+	done       bool  // EDAT seen
+}
+
+// DSCMHeader is the header sent by the BBTK before the records of a capture.
+type DSCMHeader struct {
+	Events   int   // number of records
+	Duration int64 // duration of the capture, in µs
+	Samples  int64 // number of samples
+}
+
+// NewDSCMReader returns a DSCMReader reading from r.
+func NewDSCMReader(r io.Reader) *DSCMReader {
+	return &DSCMReader{r: bufio.NewReader(r), line: 1}
+}
+
+// token returns the next ';'-terminated field, without surrounding white space, and the line
+// on which it starts. The returned slice is only valid until the next call.
+func (d *DSCMReader) token() ([]byte, int, error) {
+	oversized := false
+	for {
+		tok, err := d.r.ReadSlice(';')
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, d.line, err
+		}
+
+		start := d.line + bytes.Count(tok[:len(tok)-len(bytes.TrimLeft(tok, " \t\r\n"))], []byte{'\n'})
+		d.line += bytes.Count(tok, []byte{'\n'})
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			oversized = true // garbage: skip until the next ';'
+		case oversized:
+			oversized = false
+		default:
+			return bytes.TrimSpace(tok[:len(tok)-1]), start, nil
+		}
+	}
+}
+
+// Header returns the header of the data, reading it if Next has not been called yet.
+func (d *DSCMReader) Header() (DSCMHeader, error) {
+	if !d.headerRead {
+		if err := d.readHeader(); err != nil {
+			return DSCMHeader{}, err
+		}
+	}
+	return d.header, nil
+}
+
+// readHeader skips the input until SDAT, then reads the three fields of the header.
+func (d *DSCMReader) readHeader() error {
+	for {
+		tok, line, err := d.token()
+		if err != nil {
+			return unexpectedEOF(err, "SDAT missing")
+		}
+		if string(tok) == "SDAT" {
+			break
+		}
+		if len(tok) == 32 {
+			return &MalformedRecordError{Line: line, Record: string(tok), Reason: "record before the SDAT header"}
+		}
+	}
+
+	var values [3]int64
+	for i, name := range []string{"number of events", "duration", "number of samples"} {
+		tok, line, err := d.token()
+		if err != nil {
+			return unexpectedEOF(err, "header incomplete")
+		}
+		v, err := strconv.ParseInt(string(tok), 10, 64)
+		if err != nil || v < 0 {
+			return &MalformedRecordError{Line: line, Record: string(tok), Reason: "invalid " + name + " in the header"}
+		}
+		values[i] = v
+	}
+	d.header = DSCMHeader{Events: int(values[0]), Duration: values[1], Samples: values[2]}
+	d.headerRead = true
+	return nil
+}
+
+// Late returns the number of the records read so far which are stamped after the end of the capture.
+func (d *DSCMReader) Late() int {
+	return d.late
+}
+
+// unexpectedEOF turns the end of the input into io.ErrUnexpectedEOF, explained by what.
+func unexpectedEOF(err error, what string) error {
+	if err == io.EOF {
+		return fmt.Errorf("%w: %s", io.ErrUnexpectedEOF, what)
+	}
+	return err
+}
+
+// Next returns the next event.
+// It returns io.EOF once the EDAT marker has been read, an error matching io.ErrUnexpectedEOF
+// if the input ends before it, and an error matching ErrMalformedRecord, with the line of the
+// input, if the header or a record is invalid.
+func (d *DSCMReader) Next() (DSCEvent, error) {
+	if d.done {
+		return DSCEvent{}, io.EOF
+	}
+	if !d.headerRead {
+		if err := d.readHeader(); err != nil {
+			return DSCEvent{}, err
+		}
+	}
+
+	for {
+		tok, line, err := d.token()
+		if err != nil {
+			return DSCEvent{}, unexpectedEOF(err, fmt.Sprintf("EDAT missing after %d of %d events", d.count, d.header.Events))
+		}
+
+		switch {
+		case len(tok) == 0:
+			continue
+		case string(tok) == "EDAT":
+			d.done = true
+			if d.count != d.header.Events {
+				return DSCEvent{}, fmt.Errorf("%w: %d events announced, %d received", ErrCorruptCapture, d.header.Events, d.count)
+			}
+			return DSCEvent{}, io.EOF
+		}
+
+		e, err := parseDSCRecord(tok)
+		if m, ok := err.(*MalformedRecordError); ok {
+			m.Line = line
+		}
+		if err != nil {
+			return DSCEvent{}, err
+		}
+		if e.Timestamp < d.last {
+			return DSCEvent{}, &MalformedRecordError{Line: line, Record: string(tok), Reason: "timestamp earlier than the one of the previous record"}
+		}
+		if e.Timestamp > d.header.Duration {
+			d.late++
+		}
+		d.last = e.Timestamp
+		d.count++
+		return e, nil
+	}
+}
+
+// ReadCapture reads a whole DSCM download from r (e.g. a .dat file saved by bbtk-capture),
+// until the EDAT marker, and checks it (see DSCMReader).
+func ReadCapture(r io.Reader) (*CaptureResult, error) {
+	result := &CaptureResult{}
+	d := NewDSCMReader(r)
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Header, result.Late = d.header, d.late
+			return result, err
+		}
+		result.Events = append(result.Events, e)
+	}
+	result.Header, result.Late = d.header, d.late
+	return result, nil
+}
+
+// ReadDSCEvents reads all the events from r, until the EDAT marker.
+func ReadDSCEvents(r io.Reader) ([]DSCEvent, error) {
+	result, err := ReadCapture(r)
+	return result.Events, err
+}
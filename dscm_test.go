@@ -0,0 +1,90 @@
+package bbtkv3
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+const validDownload = "\nSDAT;\n3;\n1000000;\n4000;\n" +
+	"00000001000000000000000000000000;\n" +
+	"00000000000100000000000000100000;\n" +
+	"00000000000000000000000000150000;\n" +
+	"EDAT;\n"
+
+func TestReadCapture(t *testing.T) {
+	result, err := ReadCapture(strings.NewReader("FRMT;\nDONE;\n" + validDownload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (DSCMHeader{Events: 3, Duration: 1000000, Samples: 4000}); result.Header != want {
+		t.Errorf("header: got %+v, want %+v", result.Header, want)
+	}
+	want := []DSCEvent{{0, LineOpto1}, {100000, LineMic1}, {150000, 0}}
+	if len(result.Events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(result.Events), len(want))
+	}
+	for i, e := range want {
+		if result.Events[i] != e {
+			t.Errorf("event %d: got %+v, want %+v", i, result.Events[i], e)
+		}
+	}
+}
+
+func TestReadCaptureEmpty(t *testing.T) {
+	result, err := ReadCapture(strings.NewReader("SDAT;\n0;\n1000000;\n4000;\nEDAT;\n"))
+	if err != nil || len(result.Events) != 0 || result.Header.Duration != 1000000 {
+		t.Errorf("got %+v, %v", result, err)
+	}
+}
+
+func TestReadCaptureCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want error
+		line int // of the malformed record
+	}{
+		{"empty", "", io.ErrUnexpectedEOF, 0},
+		{"no SDAT", "00000001000000000000000000000000;\nEDAT;\n", ErrMalformedRecord, 1},
+		{"header incomplete", "SDAT;\n3;\n1000000;\n", io.ErrUnexpectedEOF, 0},
+		{"invalid header", "SDAT;\n3;\nabc;\n4000;\nEDAT;\n", ErrMalformedRecord, 3},
+		{"no EDAT", strings.TrimSuffix(validDownload, "EDAT;\n"), io.ErrUnexpectedEOF, 0},
+		{"truncated", strings.Replace(validDownload, "00000000000000000000000000150000;\n", "", 1), ErrCorruptCapture, 0},
+		{"extra record", strings.Replace(validDownload, "EDAT", "00000000000000000000000000160000;\nEDAT", 1), ErrCorruptCapture, 0},
+		{"invalid record", strings.Replace(validDownload, "000100000000000000100000", "0001000000000000001000x0", 1), ErrMalformedRecord, 7},
+		{"short record", strings.Replace(validDownload, "00000000000100000000000000100000", "0000000000010000000000000010000", 1), ErrMalformedRecord, 7},
+		{"out of order", strings.Replace(validDownload, "150000", "050000", 1), ErrMalformedRecord, 8},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadCapture(strings.NewReader(tt.data))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		var m *MalformedRecordError
+		if errors.As(err, &m) && m.Line != tt.line {
+			t.Errorf("%s: error at line %d, want %d (%v)", tt.name, m.Line, tt.line, err)
+		}
+	}
+}
+
+func TestReadCaptureLate(t *testing.T) {
+	// The records stamped after the end of the capture are kept, and counted.
+	data := strings.Replace(validDownload, "1000000;", "120000;", 1)
+	result, err := ReadCapture(strings.NewReader(data))
+	if err != nil || len(result.Events) != 3 || result.Late != 1 {
+		t.Errorf("got %d events, %d late, %v", len(result.Events), result.Late, err)
+	}
+}
+
+func TestReadCapturePartial(t *testing.T) {
+	// The events read before the problem are returned with the error.
+	data := strings.Replace(validDownload, "00000000000000000000000000150000", "garbage", 1)
+	result, err := ReadCapture(strings.NewReader(data))
+	if !errors.Is(err, ErrMalformedRecord) || len(result.Events) != 2 || result.Header.Events != 3 {
+		t.Errorf("got %d events, header %+v, %v", len(result.Events), result.Header, err)
+	}
+}
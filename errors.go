@@ -0,0 +1,66 @@
+package bbtkv3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the functions of the package. They can be tested with errors.Is.
+var (
+	ErrNotConnected    = errors.New("bbtkv3: device not connected")
+	ErrTimeout         = errors.New("bbtkv3: timeout")
+	ErrPortLost        = errors.New("bbtkv3: port lost")
+	ErrUnsupported     = errors.New("bbtkv3: not supported by this model")
+	ErrMalformedRecord = errors.New("bbtkv3: malformed record")
+	ErrCorruptCapture  = errors.New("bbtkv3: corrupt capture data")
+	ErrLineTooLong     = errors.New("bbtkv3: line too long") // more than the read buffer without an end of line
+
+	// ErrTranscriptMismatch is returned by a Replayer when the program does not send
+	// what was recorded in the transcript.
+	ErrTranscriptMismatch = errors.New("bbtkv3: transcript mismatch")
+)
+
+// UnexpectedResponseError is returned when the BBTK does not answer a command as expected.
+type UnexpectedResponseError struct {
+	Command  string // command sent to the BBTK
+	Expected string // description of the expected answer
+	Got      string // answer received
+}
+
+func (e *UnexpectedResponseError) Error() string {
+	return fmt.Sprintf("bbtkv3: %s: expected %q, got %q", e.Command, e.Expected, e.Got)
+}
+
+// MalformedRecordError is returned when a record of a DSCM download cannot be parsed.
+// It matches ErrMalformedRecord with errors.Is.
+type MalformedRecordError struct {
+	Line   int // line of the download, from 1 (0 if unknown)
+	Record string
+	Reason string
+}
+
+func (e *MalformedRecordError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("bbtkv3: line %d: malformed record %q: %s", e.Line, e.Record, e.Reason)
+	}
+	return fmt.Sprintf("bbtkv3: malformed record %q: %s", e.Record, e.Reason)
+}
+
+func (e *MalformedRecordError) Is(target error) bool {
+	return target == ErrMalformedRecord
+}
+
+// UnsupportedError is returned when a feature is not supported by the model of BBTK.
+// It matches ErrUnsupported with errors.Is.
+type UnsupportedError struct {
+	Model   Model
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("bbtkv3: %s not supported by the %v", e.Feature, e.Model)
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
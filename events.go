@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"math/bits"
 	"os"
 	"strconv"
 
@@ -32,21 +33,50 @@ type PortState map[string]int
 
 // DSCEvent represents a single event (transition) with timestamp and port states
 type DSCEvent struct {
-	Timestamp  float64
-	PortStates map[string]int
+	Timestamp int64    // in µs since the start of the capture
+	Lines     LineMask // active lines
 }
 
-// Edge represents a binary signal edge with its position
-type Edge struct {
-	Position int
-	Time     float64
+// Has tells if all the lines of l are active.
+func (e DSCEvent) Has(l LineMask) bool {
+	return e.Lines.Has(l)
+}
+
+// Changed returns the lines whose state differs from prev.
+func (e DSCEvent) Changed(prev DSCEvent) LineMask {
+	return e.Lines ^ prev.Lines
+}
+
+// String formats the event as a DSC record, e.g. "11001100110001010101000000123456".
+func (e DSCEvent) String() string {
+	return fmt.Sprintf("%s%012d", e.Lines, e.Timestamp)
+}
+
+// PortStates returns the states of the lines (1 when active), indexed by their names.
+func (e DSCEvent) PortStates() PortState {
+	states := make(PortState, NumLines)
+	for i, name := range lineNames {
+		states[name] = int(e.Lines>>i) & 1
+	}
+	return states
 }
 
 // Event represents a complete event with type, onset time, and duration
 type Event struct {
 	Type     string
-	Onset    float64
-	Duration float64
+	Onset    int64 // in µs since the start of the capture
+	Duration int64 // in µs
+
+	// ActiveAtStart tells that the line was already active when the capture started:
+	// the real onset is earlier than Onset (left-censored event).
+	ActiveAtStart bool
+	// ActiveAtEnd tells that the line was still active at the end of the capture: the event
+	// lasted at least Duration, which runs until then (right-censored event).
+	ActiveAtEnd bool
+
+	// SmoothingCorrection is the latency of smoothing, in µs, already subtracted from
+	// Duration (see SmoothingCorrection.Apply); 0 if the duration was not corrected.
+	SmoothingCorrection int64
 }
 
 // OutputPortMask8ToSeries converts an 8-bit string to a map of port states
@@ -66,64 +96,52 @@ func OutputPortMask8ToSeries(mask8 string) (PortState, error) {
 	return result, nil
 }
 
+// Txt2DSCEvent parses a 32-character DSC record: the states of the 20 lines followed by
+// the timestamp (in microseconds).
 func Txt2DSCEvent(txt string) (*DSCEvent, error) {
-
-	timestamp, err := strconv.ParseFloat(txt[20:], 64)
+	e, err := parseDSCRecord([]byte(txt))
 	if err != nil {
-		return nil, errors.New("invalid timestamp format")
+		return nil, err
 	}
-	timestamp /= 1000.0 // Convert to milliseconds
+	return &e, nil
+}
 
-	// Process port states
-	portStates := make(map[string]int)
-	for i, name := range append(InputPortNames, OutputPortNames...) {
-		bit, err := strconv.Atoi(string(txt[i]))
-		if err != nil {
-			return nil, errors.New("invalid port state format")
-		}
-		portStates[name] = bit
+// parseDSCRecord parses a DSC record without allocating, except for errors.
+func parseDSCRecord(rec []byte) (DSCEvent, error) {
+	if len(rec) != 32 {
+		return DSCEvent{}, &MalformedRecordError{Record: string(rec), Reason: "expected 32 characters"}
 	}
 
-	return &DSCEvent{Timestamp: timestamp, PortStates: portStates}, nil
-}
-
-// CaptureOutputToEvents converts DSC command output text to a slice of events
-func CaptureOutputToEvents(text string) ([]DSCEvent, error) {
-	var events []DSCEvent
-
-	lines := strings.Split(text, ";")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if len(line) == 32 {
-			// Extract timestamp (converting microseconds to milliseconds)
-			timestamp, err := strconv.ParseFloat(line[20:], 64)
-			if err != nil {
-				return nil, errors.New("invalid timestamp format")
-			}
-			timestamp /= 1000.0 // Convert to milliseconds
-
-			// Process port states
-			portStates := make(map[string]int)
-			for i, name := range append(InputPortNames, OutputPortNames...) {
-				bit, err := strconv.Atoi(string(line[i]))
-				if err != nil {
-					return nil, errors.New("invalid port state format")
-				}
-				portStates[name] = bit
-			}
+	var e DSCEvent
+	for i, c := range rec[:NumLines] {
+		switch c {
+		case '1':
+			e.Lines |= 1 << (NumLines - 1 - i)
+		case '0':
+		default:
+			return DSCEvent{}, &MalformedRecordError{Record: string(rec), Reason: "invalid port state format"}
+		}
+	}
 
-			events = append(events, DSCEvent{
-				Timestamp:  timestamp,
-				PortStates: portStates,
-			})
+	for _, c := range rec[NumLines:] {
+		if c < '0' || c > '9' {
+			return DSCEvent{}, &MalformedRecordError{Record: string(rec), Reason: "invalid timestamp format"}
 		}
+		e.Timestamp = e.Timestamp*10 + int64(c-'0')
 	}
 
-	return events, nil
+	return e, nil
+}
+
+// CaptureOutputToEvents converts DSC command output text to a slice of events.
+// The text is checked as by ReadCapture: an incomplete or damaged text gives an error,
+// with the events read before the problem.
+func CaptureOutputToEvents(text string) ([]DSCEvent, error) {
+	return ReadDSCEvents(strings.NewReader(text))
 }
 
-// SaveDSCEventsToCSV saves a slice of DSCEvents to a CSV file
-func SaveDSCEventsToCSV(events []DSCEvent, filename string) error {
+// SaveDSCEventsToCSV saves a slice of DSCEvents to a CSV file, with the timestamps in unit
+func SaveDSCEventsToCSV(events []DSCEvent, filename string, unit TimeUnit) error {
 	// Create or truncate the file
 	file, err := os.Create(filename)
 	if err != nil {
@@ -146,14 +164,11 @@ func SaveDSCEventsToCSV(events []DSCEvent, filename string) error {
 		row := make([]string, len(DSCLineNames))
 
 		// First column is timestamp
-		row[0] = strconv.FormatFloat(event.Timestamp, 'f', 3, 64)
+		row[0] = unit.Format(event.Timestamp)
 
-		// Fill in port states in the correct order
-		for i, portName := range InputPortNames {
-			row[i+1] = strconv.Itoa(event.PortStates[portName])
-		}
-		for i, portName := range OutputPortNames {
-			row[i+1+len(InputPortNames)] = strconv.Itoa(event.PortStates[portName])
+		// Fill in port states in the order of the record
+		for i := 0; i < NumLines; i++ {
+			row[i+1] = boolToBit(event.Has(1 << (NumLines - 1 - i)))
 		}
 
 		if err := writer.Write(row); err != nil {
@@ -164,86 +179,82 @@ func SaveDSCEventsToCSV(events []DSCEvent, filename string) error {
 	return nil
 }
 
-// LocateEdges finds the positions of leading and falling edges in a binary sequence
-func LocateEdges(sequence []int) ([]Edge, []Edge, error) {
-	if len(sequence) <= 2 {
-		return nil, nil, errors.New("sequence too short")
-	}
-
-	// Check baseline conditions
-	if sequence[0] != 0 {
-		return nil, nil, errors.New("signal must start at baseline (0)")
-	}
-	if sequence[len(sequence)-1] != 0 {
-		return nil, nil, errors.New("signal must end at baseline (0)")
-	}
-
-	var leadingEdges []Edge
-	var fallingEdges []Edge
-
-	// Find edges by comparing adjacent values
-	for i := 1; i < len(sequence); i++ {
-		if sequence[i-1] == 0 && sequence[i] == 1 {
-			leadingEdges = append(leadingEdges, Edge{Position: i})
-		} else if sequence[i-1] == 1 && sequence[i] == 0 {
-			fallingEdges = append(fallingEdges, Edge{Position: i})
-		}
-	}
-
-	return leadingEdges, fallingEdges, nil
-}
-
-// CaptureEventsFromDSCEvents converts raw DSC events into a slice of detected events
+// CaptureEventsFromDSCEvents converts raw DSC events into a slice of detected events, sorted
+// by input line (in the order of InputPortNames), then by onset.
+//
+// The lines are taken to be inactive before the first record, unless it is at time 0, which
+// is taken to mean that they were active when the capture started (see Event.ActiveAtStart).
+// This assumes that the BBTK records the state of the lines at time 0 when some are active
+// then, as the simulator does; the documentation of the firmware does not tell.
+//
+// The lines still active at the last record give events flagged with Event.ActiveAtEnd, which
+// end at that record, the end of the capture being unknown (see CaptureEventsFromCapture).
 func CaptureEventsFromDSCEvents(rawEvents []DSCEvent) ([]Event, error) {
 	if len(rawEvents) == 0 {
-		return nil, errors.New("no events provided")
+		return []Event{}, nil
 	}
+	return captureEvents(rawEvents, rawEvents[len(rawEvents)-1].Timestamp), nil
+}
 
-	var allEvents []Event
-
-	// Process each input port
-	for _, portName := range InputPortNames {
-		// Extract binary sequence for this port
-		sequence := make([]int, len(rawEvents))
-		timestamps := make([]float64, len(rawEvents))
-
-		// Force first value to 0 (baseline)
-		sequence[0] = 0
-		timestamps[0] = rawEvents[0].Timestamp
+// CaptureEventsFromCapture is CaptureEventsFromDSCEvents for the events of a capture, whose
+// right-censored events end at the end of the capture (Header.Duration), or at the last record
+// if it is stamped after it (see CaptureResult.Late).
+func CaptureEventsFromCapture(result *CaptureResult) ([]Event, error) {
+	end := result.Header.Duration
+	if n := len(result.Events); n > 0 {
+		end = max(end, result.Events[n-1].Timestamp)
+	}
+	return captureEvents(result.Events, end), nil
+}
 
-		// Fill the rest of the sequence
-		for i := 1; i < len(rawEvents); i++ {
-			sequence[i] = rawEvents[i].PortStates[portName]
-			timestamps[i] = rawEvents[i].Timestamp
+// captureEvents detects the events in rawEvents, the lines still active at the end being
+// taken to become inactive at time end.
+func captureEvents(rawEvents []DSCEvent, end int64) []Event {
+	var perLine [NumLines][]Event
+	var onsets [NumLines]int // record at which each active line became active
+
+	newEvent := func(bit, onset int, offset int64) Event {
+		return Event{
+			Type:          lineNames[bit],
+			Onset:         rawEvents[onset].Timestamp,
+			Duration:      offset - rawEvents[onset].Timestamp,
+			ActiveAtStart: onset == 0 && rawEvents[0].Timestamp == 0,
 		}
+	}
 
-		// Locate edges
-		leadingEdges, fallingEdges, err := LocateEdges(sequence)
-		if err != nil {
-			return nil, fmt.Errorf("error processing port %s: %w", portName, err)
+	// The edges are the bits which differ between successive records, starting from the
+	// baseline (all lines inactive) before the first record
+	var prev DSCEvent
+	for i, e := range rawEvents {
+		for changed := e.Changed(prev) & InputLines; changed != 0; changed &= changed - 1 {
+			bit := bits.TrailingZeros32(uint32(changed))
+			if e.Lines&(1<<bit) != 0 {
+				onsets[bit] = i
+			} else {
+				perLine[bit] = append(perLine[bit], newEvent(bit, onsets[bit], e.Timestamp))
+			}
 		}
+		prev = e
+	}
 
-		// Skip if no events detected
-		if len(leadingEdges) == 0 {
-			continue
-		}
+	for active := prev.Lines & InputLines; active != 0; active &= active - 1 {
+		bit := bits.TrailingZeros32(uint32(active))
+		event := newEvent(bit, onsets[bit], end)
+		event.ActiveAtEnd = true
+		perLine[bit] = append(perLine[bit], event)
+	}
 
-		// Create events from edges
-		for i := 0; i < len(leadingEdges); i++ {
-			event := Event{
-				Type:     portName,
-				Onset:    timestamps[leadingEdges[i].Position],
-				Duration: timestamps[fallingEdges[i].Position] - timestamps[leadingEdges[i].Position],
-			}
-			allEvents = append(allEvents, event)
-		}
+	// InputPortNames lists the input lines from the highest bit
+	allEvents := []Event{}
+	for bit := NumLines - 1; bit >= 0; bit-- {
+		allEvents = append(allEvents, perLine[bit]...)
 	}
 
-	return allEvents, nil
+	return allEvents
 }
 
-// SaveEventsToCSV saves detected events to a CSV file
-func SaveEventsToCSV(events []Event, filename string) error {
+// SaveEventsToCSV saves detected events to a CSV file, with the onsets and durations in unit
+func SaveEventsToCSV(events []Event, filename string, unit TimeUnit) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("error creating file: %w", err)
@@ -254,7 +265,7 @@ func SaveEventsToCSV(events []Event, filename string) error {
 	defer writer.Flush()
 
 	// Write header
-	if err := writer.Write([]string{"Type", "Onset", "Duration"}); err != nil {
+	if err := writer.Write([]string{"Type", "Onset", "Duration", "ActiveAtStart", "ActiveAtEnd", "SmoothingCorrection"}); err != nil {
 		return fmt.Errorf("error writing header: %w", err)
 	}
 
@@ -262,8 +273,11 @@ func SaveEventsToCSV(events []Event, filename string) error {
 	for _, event := range events {
 		row := []string{
 			event.Type,
-			strconv.FormatFloat(event.Onset, 'f', 3, 64),
-			strconv.FormatFloat(event.Duration, 'f', 3, 64),
+			unit.Format(event.Onset),
+			unit.Format(event.Duration),
+			boolToBit(event.ActiveAtStart),
+			boolToBit(event.ActiveAtEnd),
+			unit.Format(event.SmoothingCorrection),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("error writing row: %w", err)
@@ -272,3 +286,10 @@ func SaveEventsToCSV(events []Event, filename string) error {
 
 	return nil
 }
+
+func boolToBit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
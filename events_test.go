@@ -0,0 +1,93 @@
+package bbtkv3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCaptureEventsFromDSCEvents(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []DSCEvent
+		want []Event
+	}{
+		{"no events", nil, []Event{}},
+		{
+			"pulses",
+			[]DSCEvent{
+				{100, LineOpto1}, {150, 0},
+				{200, LineMic1}, {300, LineMic1 | LineOpto1}, {350, LineOpto1}, {400, 0},
+			},
+			[]Event{
+				{Type: "Opto1", Onset: 100, Duration: 50},
+				{Type: "Opto1", Onset: 300, Duration: 100},
+				{Type: "Mic1", Onset: 200, Duration: 150},
+			},
+		},
+		{
+			"active at start",
+			[]DSCEvent{{0, LineKeypad2}, {500, 0}},
+			[]Event{{Type: "Keypad2", Onset: 0, Duration: 500, ActiveAtStart: true}},
+		},
+		{
+			"first record later",
+			[]DSCEvent{{10, LineKeypad2}, {500, 0}},
+			[]Event{{Type: "Keypad2", Onset: 10, Duration: 490}},
+		},
+		{
+			"active at end",
+			[]DSCEvent{{100, LineTTLin1}, {200, LineTTLin1 | LineOpto4}},
+			[]Event{
+				{Type: "Opto4", Onset: 200, Duration: 0, ActiveAtEnd: true},
+				{Type: "TTLin1", Onset: 100, Duration: 100, ActiveAtEnd: true},
+			},
+		},
+		{
+			"output lines ignored",
+			[]DSCEvent{{100, LineMask(Sounder1)}, {200, LineMask(Sounder1) | LineMic2}, {300, 0}},
+			[]Event{{Type: "Mic2", Onset: 200, Duration: 100}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := CaptureEventsFromDSCEvents(tt.raw)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCaptureEventsFromCapture(t *testing.T) {
+	result := &CaptureResult{
+		Header: DSCMHeader{Events: 3, Duration: 1000},
+		Events: []DSCEvent{{0, LineOpto1}, {300, LineMic1}, {400, 0}},
+	}
+	want := []Event{
+		{Type: "Opto1", Onset: 0, Duration: 300, ActiveAtStart: true},
+		{Type: "Mic1", Onset: 300, Duration: 100},
+	}
+	got, err := CaptureEventsFromCapture(result)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, %v\nwant %+v", got, err, want)
+	}
+
+	// The events still going on end at the end of the capture, not at the last record.
+	result.Events = result.Events[:2]
+	want[1] = Event{Type: "Mic1", Onset: 300, Duration: 700, ActiveAtEnd: true}
+	got, err = CaptureEventsFromCapture(result)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("censored: got %+v, %v\nwant %+v", got, err, want)
+	}
+
+	// A record stamped after the end ends the censored events.
+	result.Header.Duration = 200
+	want[1].Duration = 0
+	got, err = CaptureEventsFromCapture(result)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("record after the end: got %+v, %v\nwant %+v", got, err, want)
+	}
+}
@@ -0,0 +1,176 @@
+package bbtkv3
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Model is the model of a BBTK.
+type Model int
+
+const (
+	ModelUnknown Model = iota
+	ModelV2
+	ModelV3
+)
+
+func (m Model) String() string {
+	switch m {
+	case ModelV2:
+		return "BBTKv2"
+	case ModelV3:
+		return "BBTKv3"
+	}
+	return "unknown BBTK model"
+}
+
+// FirmwareVersion is the answer of the BBTK to FIRM, e.g. "BBTKv3 Firmware v3.1.2;".
+type FirmwareVersion struct {
+	Raw                 string
+	Model               Model
+	Major, Minor, Patch int
+}
+
+func (v FirmwareVersion) String() string {
+	return fmt.Sprintf("%v firmware %d.%d.%d", v.Model, v.Major, v.Minor, v.Patch)
+}
+
+var (
+	modelRegexp   = regexp.MustCompile(`(?i)BBTK\s*v?([23])\b`)
+	versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+// ParseFirmware parses the answer of the BBTK to FIRM. When the model cannot be
+// recognized, it is ModelUnknown, and the error tells why.
+func ParseFirmware(s string) (FirmwareVersion, error) {
+	v := FirmwareVersion{Raw: s}
+	s = strings.TrimSuffix(strings.TrimSpace(s), ";")
+
+	if m := modelRegexp.FindStringSubmatch(s); m != nil {
+		switch m[1] {
+		case "2":
+			v.Model = ModelV2
+		case "3":
+			v.Model = ModelV3
+		}
+	}
+
+	if m := versionRegexp.FindStringSubmatch(s); m != nil {
+		v.Major, _ = strconv.Atoi(m[1])
+		v.Minor, _ = strconv.Atoi(m[2])
+		v.Patch, _ = strconv.Atoi(m[3])
+		if v.Model == ModelUnknown {
+			// e.g. "Firmware v3.1.2": the major version tells the model.
+			switch v.Major {
+			case 2:
+				v.Model = ModelV2
+			case 3:
+				v.Model = ModelV3
+			}
+		}
+	} else if v.Model == ModelUnknown {
+		return v, &UnexpectedResponseError{Command: "FIRM", Expected: "a firmware version", Got: v.Raw}
+	}
+
+	if v.Model == ModelUnknown {
+		return v, fmt.Errorf("bbtkv3: unknown BBTK model in %q", v.Raw)
+	}
+	return v, nil
+}
+
+// Capabilities tells how a model of BBTK differs from the others.
+//
+// The sets of commands supported by each model, and the sizes of their memories (which bound
+// the length of the captures), are not known: all the commands are sent to all the models,
+// and the length of the captures is not checked.
+type Capabilities struct {
+	Model           Model
+	BreakAllowed    bool // a serial break can be sent (it is harmful on the BBTKv3)
+	DefaultBaudrate int  // rate of the serial port out of the factory (0 if unknown)
+
+	// SmoothingLatency is how late the offsets are detected on the lines with smoothing on.
+	// It is the Latency of CaptureResult.Smoothing, unless changed with Bbtkv3.SetSmoothingLatency.
+	SmoothingLatency time.Duration
+}
+
+var modelCapabilities = map[Model]Capabilities{
+	ModelV2: {
+		Model:            ModelV2,
+		BreakAllowed:     true,
+		DefaultBaudrate:  230400,
+		SmoothingLatency: 20 * time.Millisecond,
+	},
+	ModelV3: {
+		Model:            ModelV3,
+		BreakAllowed:     false,
+		DefaultBaudrate:  115200,
+		SmoothingLatency: 20 * time.Millisecond,
+	},
+	// Until the model is known, no break is sent.
+	ModelUnknown: {
+		Model:            ModelUnknown,
+		BreakAllowed:     false,
+		SmoothingLatency: 20 * time.Millisecond,
+	},
+}
+
+// CapabilitiesOf returns the capabilities of the model m.
+func CapabilitiesOf(m Model) Capabilities {
+	return modelCapabilities[m]
+}
+
+// Identify asks the BBTK for its firmware version (FIRM) and sets the capabilities
+// of b according to its model. Connect calls it.
+func (b *Bbtkv3) Identify() (FirmwareVersion, error) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.identify()
+}
+
+func (b *Bbtkv3) identify() (FirmwareVersion, error) {
+	resp, err := b.getFirmwareVersion()
+	if err != nil {
+		return FirmwareVersion{}, fmt.Errorf("Identify: %w", err)
+	}
+	v, err := ParseFirmware(resp)
+	b.firmware = v
+	b.caps = CapabilitiesOf(v.Model)
+	if err != nil {
+		return v, fmt.Errorf("Identify: %w", err)
+	}
+	b.logger.Info("identified", "model", v.Model, "firmware", fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch))
+	return v, nil
+}
+
+// Firmware returns the firmware version found by Identify.
+func (b *Bbtkv3) Firmware() FirmwareVersion {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.firmware
+}
+
+// Capabilities returns the capabilities of the BBTK, as found by Identify. Before,
+// those of ModelUnknown are assumed.
+func (b *Bbtkv3) Capabilities() Capabilities {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.caps
+}
+
+// leaveMode takes the BBTK out of a mode which it only leaves on a serial break (on the BBTKv2)
+// or on the next command (on the BBTKv3), such as input check or pulse-train generation,
+// and waits until it answers commands again.
+func (b *Bbtkv3) leaveMode(mode string) error {
+	if b.caps.BreakAllowed {
+		if err := b.port.Break(BreakDuration); err != nil {
+			return err
+		}
+	}
+	return b.sync(mode)
+}
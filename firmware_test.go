@@ -0,0 +1,49 @@
+package bbtkv3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseFirmware(t *testing.T) {
+	tests := []struct {
+		answer              string
+		model               Model
+		major, minor, patch int
+		err                 bool
+	}{
+		{"BBTKv3 Firmware v3.1.2;", ModelV3, 3, 1, 2, false},
+		{"BBTK v2 firmware 2.5;\r\n", ModelV2, 2, 5, 0, false},
+		{"bbtkv2;", ModelV2, 0, 0, 0, false},
+		{"Firmware v3.0.7;", ModelV3, 3, 0, 7, false}, // model from the major version
+		{"Firmware v4.1;", ModelUnknown, 4, 1, 0, true},
+		{"BBTK;", ModelUnknown, 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseFirmware(tt.answer)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseFirmware(%q): error %v", tt.answer, err)
+		}
+		if v.Raw != tt.answer || v.Model != tt.model || v.Major != tt.major || v.Minor != tt.minor || v.Patch != tt.patch {
+			t.Errorf("ParseFirmware(%q) = %+v", tt.answer, v)
+		}
+	}
+}
+
+func TestParseFirmwareUnexpected(t *testing.T) {
+	_, err := ParseFirmware("BBTK;")
+	var u *UnexpectedResponseError
+	if !errors.As(err, &u) || u.Command != "FIRM" {
+		t.Errorf("ParseFirmware(\"BBTK;\"): got %v, want an UnexpectedResponseError", err)
+	}
+}
+
+func TestKnownBaudrates(t *testing.T) {
+	want := []int{115200, 230400}
+	for i, rate := range want {
+		if KnownBaudrates[i] != rate {
+			t.Errorf("KnownBaudrates[%d] = %d, want %d", i, KnownBaudrates[i], rate)
+		}
+	}
+}
@@ -2,9 +2,10 @@ module github.com/chrplr/bbtkv3
 
 go 1.24.0
 
-require go.bug.st/serial v1.6.2
-
 require (
-	github.com/creack/goselect v0.1.2 // indirect
-	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	go.bug.st/serial v1.6.2
+	golang.org/x/sys v0.0.0-20220829200755-d48e67d00261
 )
+
+require github.com/creack/goselect v0.1.2 // indirect
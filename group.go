@@ -0,0 +1,236 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeviceConfig describes one of the BBTKs of a Group.
+type DeviceConfig struct {
+	Port     string
+	Baudrate int
+
+	// Settings applied when the group is opened; nil keeps those of the box.
+	Thresholds *Thresholds
+	Smoothing  *SmoothingMask
+
+	// SmoothingLatency, if not nil, is set with Bbtkv3.SetSmoothingLatency.
+	SmoothingLatency *time.Duration
+}
+
+// Group drives several BBTKs together, e.g. one per screen or per participant station,
+// so that their captures start at (almost) the same time.
+type Group struct {
+	Devices []*Bbtkv3
+	logger  *slog.Logger
+}
+
+// GroupCaptureResult holds the outcome of a capture by a Group.
+type GroupCaptureResult struct {
+	Results []*CaptureResult // per device; nil for the devices whose capture failed
+
+	// ArmSkew is the spread of the times at which the captures were started (RUDS sent),
+	// as measured on the host.
+	ArmSkew time.Duration
+}
+
+// OpenGroup opens the BBTKs described by configs, connects to them and applies their settings,
+// all concurrently. If one of them fails, the others are closed.
+func OpenGroup(configs []DeviceConfig, logger *slog.Logger) (*Group, error) {
+	if logger == nil {
+		logger = discardLogger
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("OpenGroup: no device")
+	}
+
+	g := &Group{
+		Devices: make([]*Bbtkv3, len(configs)),
+		logger:  logger,
+	}
+	errs := make([]error, len(configs))
+
+	var wg sync.WaitGroup
+	for i, cfg := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Devices[i], errs[i] = openConfigured(cfg, logger)
+		}()
+	}
+	wg.Wait()
+
+	var all []error
+	for i, err := range errs {
+		if err != nil {
+			all = append(all, fmt.Errorf("%s: %w", configs[i].Port, err))
+		}
+	}
+	if err := errors.Join(all...); err != nil {
+		g.Close()
+		return nil, fmt.Errorf("OpenGroup: %w", err)
+	}
+	return g, nil
+}
+
+// NewGroup makes a group of BBTKs which have already been opened and connected
+// (e.g. over other transports than serial ports).
+func NewGroup(devices []*Bbtkv3, logger *slog.Logger) *Group {
+	if logger == nil {
+		logger = discardLogger
+	}
+	return &Group{Devices: devices, logger: logger}
+}
+
+// openConfigured opens and sets up the BBTK described by cfg.
+func openConfigured(cfg DeviceConfig, logger *slog.Logger) (*Bbtkv3, error) {
+	b, err := NewBbtkv3(cfg.Port, cfg.Baudrate, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.SmoothingLatency != nil {
+		b.SetSmoothingLatency(*cfg.SmoothingLatency)
+	}
+	b.ResetSerialBuffers()
+	err = b.Connect()
+	if err == nil && cfg.Smoothing != nil {
+		err = b.SetSmoothing(*cfg.Smoothing)
+	}
+	if err == nil && cfg.Thresholds != nil {
+		err = b.SetThresholds(*cfg.Thresholds)
+	}
+	if err != nil {
+		b.Disconnect()
+		return nil, err
+	}
+	return b, nil
+}
+
+// join combines the errors of the devices, prefixing them with the ports.
+func (g *Group) join(errs []error) error {
+	var all []error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		name := fmt.Sprintf("device %d", i+1)
+		if p := g.Devices[i].portAddress; p != "" {
+			name = p
+		}
+		all = append(all, fmt.Errorf("%s: %w", name, err))
+	}
+	return errors.Join(all...)
+}
+
+// Close disconnects all the BBTKs of the group.
+func (g *Group) Close() error {
+	errs := make([]error, len(g.Devices))
+	for i, b := range g.Devices {
+		if b != nil {
+			errs[i] = b.Disconnect()
+		}
+	}
+	return g.join(errs)
+}
+
+// ClearTimingData clears the memory of all the BBTKs, concurrently.
+func (g *Group) ClearTimingData() error {
+	errs := make([]error, len(g.Devices))
+	var wg sync.WaitGroup
+	for i, b := range g.Devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.ClearTimingData()
+		}()
+	}
+	wg.Wait()
+	return g.join(errs)
+}
+
+// Capture captures the events on all the BBTKs for the given duration. The captures are
+// first armed (DSCM, TIML) on all the boxes; once they all are, RUDS is sent to each of
+// them in parallel, so that they start with minimal skew. The data are then downloaded
+// concurrently. If raw is not nil, the text sent by the i-th box is copied to raw[i]
+// (which may be nil).
+//
+// If any box fails to arm, none is started. Otherwise the result holds the events of the
+// boxes whose capture succeeded, and the error reports the others.
+func (g *Group) Capture(ctx context.Context, duration time.Duration, raw []io.Writer) (*GroupCaptureResult, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("Capture: invalid duration %v", duration)
+	}
+
+	n := len(g.Devices)
+	result := &GroupCaptureResult{Results: make([]*CaptureResult, n)}
+	errs := make([]error, n)
+
+	var armed, done sync.WaitGroup
+	var aborted atomic.Bool
+	start := make(chan struct{})
+
+	armed.Add(n)
+	done.Add(n)
+	for i, b := range g.Devices {
+		var w io.Writer
+		if i < len(raw) {
+			w = raw[i]
+		}
+		go func() {
+			defer done.Done()
+			b.mu.Lock()
+			defer b.unlock()
+			errs[i] = b.arm(duration)
+			armed.Done()
+			if errs[i] != nil {
+				return
+			}
+			<-start
+			if aborted.Load() {
+				return
+			}
+			result.Results[i], errs[i] = b.run(ctx, duration, w, &CaptureSession{})
+		}()
+	}
+
+	armed.Wait()
+	if err := g.join(errs); err != nil || ctx.Err() != nil {
+		aborted.Store(true)
+		close(start)
+		done.Wait()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("Capture: %w", err)
+	}
+	g.logger.Info("all captures armed", "devices", n)
+	close(start)
+	done.Wait()
+
+	var first, last time.Time
+	for _, r := range result.Results {
+		if r == nil {
+			continue
+		}
+		if first.IsZero() || r.Start.Before(first) {
+			first = r.Start
+		}
+		if r.Start.After(last) {
+			last = r.Start
+		}
+	}
+	result.ArmSkew = last.Sub(first)
+	g.logger.Info("captures done", "skew", result.ArmSkew)
+
+	if err := g.join(errs); err != nil {
+		return result, fmt.Errorf("Capture: %w", err)
+	}
+	return result, nil
+}
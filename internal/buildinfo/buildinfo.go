@@ -0,0 +1,14 @@
+// Package buildinfo prints the version of the tools, set at link time with
+// -ldflags "-X main.Version=... -X main.Build=...".
+package buildinfo
+
+import "fmt"
+
+// Print prints the version and the build id (shortened to 8 characters, as the commit hashes)
+// of a tool. Both are empty when the tool was built without the ldflags.
+func Print(version, build string) {
+	if len(build) > 8 {
+		build = build[:8]
+	}
+	fmt.Printf("Version: %s  Build: %s\n", version, build)
+}
@@ -0,0 +1,95 @@
+package bbtkv3
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// LineMask is a set of the 20 lines of the BBTK, as recorded in DSC mode. The bits are in the
+// reverse order of the characters of a record (see DSCLineNames), so that the lowest byte is the
+// OutputMask of the output lines: these are LineMask(Sounder1), etc.
+type LineMask uint32
+
+// The input lines.
+const (
+	LineMic1 LineMask = 1 << (iota + 8)
+	LineMic2
+	LineTTLin1
+	LineTTLin2
+	LineOpto1
+	LineOpto2
+	LineOpto3
+	LineOpto4
+	LineKeypad1
+	LineKeypad2
+	LineKeypad3
+	LineKeypad4
+)
+
+// NumLines is the number of lines in a DSC record.
+const NumLines = 20
+
+// InputLines and OutputLines are the masks of all the input, and all the output, lines.
+const (
+	InputLines  LineMask = 0xFFF << 8
+	OutputLines LineMask = 0xFF
+)
+
+// lineNames are the names of the lines, indexed by bit.
+var lineNames [NumLines]string
+
+func init() {
+	for i, name := range DSCLineNames[1:] {
+		lineNames[NumLines-1-i] = name
+	}
+}
+
+// LineByName returns the line with the given name (see InputPortNames and OutputPortNames).
+func LineByName(name string) (LineMask, bool) {
+	for i, n := range lineNames {
+		if n == name {
+			return 1 << i, true
+		}
+	}
+	return 0, false
+}
+
+// String returns the mask as in a DSC record: 20 bits in the order of DSCLineNames.
+func (m LineMask) String() string {
+	return fmt.Sprintf("%020b", uint32(m))
+}
+
+// Has tells if all the lines of l are in the mask.
+func (m LineMask) Has(l LineMask) bool {
+	return m&l == l
+}
+
+// Names returns the names of the lines in the mask, in the order of DSCLineNames.
+func (m LineMask) Names() []string {
+	var names []string
+	for i := NumLines - 1; i >= 0; i-- {
+		if m&(1<<i) != 0 {
+			names = append(names, lineNames[i])
+		}
+	}
+	return names
+}
+
+// Describe lists the lines of the mask, e.g. "Opto1+Mic1", or "none".
+func (m LineMask) Describe() string {
+	if m == 0 {
+		return "none"
+	}
+	return strings.Join(m.Names(), "+")
+}
+
+// Outputs returns the output lines of the mask.
+func (m LineMask) Outputs() OutputMask {
+	return OutputMask(m & OutputLines)
+}
+
+// name returns the name of a single line.
+func (m LineMask) name() string {
+	return lineNames[bits.TrailingZeros32(uint32(m))]
+}
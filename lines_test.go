@@ -0,0 +1,66 @@
+package bbtkv3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDSCEventRoundTrip(t *testing.T) {
+	for _, e := range []DSCEvent{
+		{0, 0},
+		{123456, LineMic1 | LineKeypad4},
+		{999999999999, InputLines | OutputLines},
+		{30000000, LineMask(ActClose1) | LineOpto2},
+	} {
+		s := e.String()
+		got, err := Txt2DSCEvent(s)
+		if err != nil {
+			t.Errorf("%+v: %q: %v", e, s, err)
+			continue
+		}
+		if *got != e {
+			t.Errorf("%+v: %q parsed as %+v", e, s, *got)
+		}
+	}
+}
+
+func TestParseDSCRecord(t *testing.T) {
+	e, err := Txt2DSCEvent("10000001000000000000000000123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Timestamp != 123456 || e.Lines != LineKeypad4|LineOpto1 {
+		t.Errorf("got %+v, want Keypad4 and Opto1 at 123456", *e)
+	}
+	if got := e.Lines.Describe(); got != "Keypad4+Opto1" {
+		t.Errorf("Describe: got %q", got)
+	}
+
+	for _, rec := range []string{
+		"1000000000010000000000000012345",   // too short
+		"20000000000100000000000000123456",  // invalid state
+		"1000000000010000000000000012345x",  // invalid timestamp
+		"100000000001000000000000001234567", // too long
+	} {
+		if _, err := Txt2DSCEvent(rec); !errors.Is(err, ErrMalformedRecord) {
+			t.Errorf("%q: got %v, want ErrMalformedRecord", rec, err)
+		}
+	}
+}
+
+func TestLineByName(t *testing.T) {
+	for i, name := range DSCLineNames[1:] {
+		l, ok := LineByName(name)
+		if !ok || l != 1<<(NumLines-1-i) || l.name() != name {
+			t.Errorf("LineByName(%q) = %v, %v", name, l, ok)
+		}
+	}
+	for i, name := range OutputPortNames {
+		if l, _ := LineByName(name); l.Outputs() != OutputMask(1<<(7-i)) {
+			t.Errorf("%s: got %v", name, l.Outputs())
+		}
+	}
+	if _, ok := LineByName("Opto5"); ok {
+		t.Error("LineByName(\"Opto5\") found a line")
+	}
+}
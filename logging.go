@@ -0,0 +1,36 @@
+package bbtkv3
+
+import (
+	"io"
+	"log/slog"
+)
+
+// LevelTrace is the log level of the protocol trace: every command sent to
+// and every line received from the BBTK.
+const LevelTrace = slog.LevelDebug - 4
+
+// NewLogger returns a logger writing text to w, suitable for command-line tools.
+// By default only warnings and errors are shown; verbose adds informational messages,
+// debug adds debugging messages and the protocol trace.
+func NewLogger(w io.Writer, verbose, debug bool) *slog.Logger {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	if debug {
+		level = LevelTrace
+	}
+
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	}))
+}
+
+// discardLogger is used when no logger is provided.
+var discardLogger = slog.New(slog.DiscardHandler)
@@ -0,0 +1,119 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BreakDuration is the length of the serial breaks sent to the BBTK (on the models which allow them).
+var BreakDuration = 10 * time.Millisecond
+
+// InputState is the state of the 12 input lines, as reported by the BBTK in input check mode.
+type InputState struct {
+	Time  time.Time // when the report was received, according to the clock of the host
+	Lines LineMask  // the active input lines
+}
+
+// parseInputReport parses a report of the BBTK in input check mode: the states of the 12 input
+// lines, in the order of InputPortNames, e.g. "000000010000;".
+func parseInputReport(report string) (LineMask, error) {
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(report), ";"))
+	if len(s) != 12 {
+		return 0, errors.New("mask must be exactly 12 bits long")
+	}
+	v, err := strconv.ParseUint(s, 2, 12)
+	if err != nil {
+		return 0, errors.New("mask must contain only binary digits (0 or 1)")
+	}
+	return LineMask(v) << 8, nil
+}
+
+// InputMonitor is an input line check running in the background, started by MonitorInputs.
+type InputMonitor struct {
+	states chan InputState
+	done   chan struct{}
+	err    error
+}
+
+// States returns the channel on which the states of the input lines are sent as they are
+// reported by the BBTK. It is closed when the monitor stops.
+func (m *InputMonitor) States() <-chan InputState {
+	return m.states
+}
+
+// Done returns a channel which is closed when the monitor stops.
+func (m *InputMonitor) Done() <-chan struct{} {
+	return m.done
+}
+
+// Wait waits for the monitor to stop. It returns nil if it was stopped by the cancellation
+// of its context and the BBTK left the input check mode.
+func (m *InputMonitor) Wait() error {
+	<-m.done
+	return m.err
+}
+
+// MonitorInputs puts the BBTK in input check mode (ICHK), where it reports the state of its
+// input lines (Keypad1-4, Opto1-4, TTLin1-2, Mic1-2) as they change, and sends these
+// states on the States channel of the returned monitor until ctx is cancelled. This permits
+// to check the placement of the sensors and their thresholds without running a capture.
+//
+// When ctx is cancelled, the BBTK is taken out of input check mode: with a serial break on the
+// BBTKv2, which requires a Transport able to send one (a serial port is), with a command on the BBTKv3.
+// The other goroutines using b wait until the monitor stops.
+func (b *Bbtkv3) MonitorInputs(ctx context.Context) (*InputMonitor, error) {
+	b.mu.Lock()
+	if err := b.send("ICHK"); err != nil {
+		b.unlock()
+		return nil, fmt.Errorf("MonitorInputs: %w", err)
+	}
+
+	m := &InputMonitor{
+		states: make(chan InputState),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(m.done)
+		defer close(m.states)
+		defer b.unlock()
+		m.err = b.runInputMonitor(ctx, m)
+	}()
+
+	return m, nil
+}
+
+func (b *Bbtkv3) runInputMonitor(ctx context.Context, m *InputMonitor) error {
+	b.logger.Info("input check started")
+
+	for ctx.Err() == nil {
+		line, err := b.readLine()
+		if errors.Is(err, ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("MonitorInputs: %w", err)
+		}
+
+		lines, err := parseInputReport(line)
+		if err != nil {
+			b.logger.Debug("MonitorInputs: unexpected line", "line", line)
+			continue
+		}
+
+		select {
+		case m.states <- InputState{Time: time.Now(), Lines: lines}:
+		case <-ctx.Done():
+		}
+	}
+
+	if err := b.leaveMode("ICHK"); err != nil {
+		return fmt.Errorf("MonitorInputs: leaving input check mode: %w", err)
+	}
+	b.logger.Info("input check stopped")
+	return nil
+}
@@ -0,0 +1,36 @@
+package bbtkv3
+
+import "testing"
+
+func TestParseInputReport(t *testing.T) {
+	tests := []struct {
+		report string
+		want   LineMask
+	}{
+		{"000000000000;", 0},
+		{"100000000000;", LineKeypad4},
+		{"000000010001;\r\n", LineOpto1 | LineMic1},
+		{" 111111111111 ;", InputLines},
+		{"000000000100", LineTTLin1},
+	}
+	for _, tt := range tests {
+		got, err := parseInputReport(tt.report)
+		if err != nil || got != tt.want {
+			t.Errorf("parseInputReport(%q) = %v, %v, want %v", tt.report, got.Describe(), err, tt.want.Describe())
+		}
+	}
+
+	for _, report := range []string{
+		"",
+		"BBTK;",
+		"00000000000;",   // 11 lines
+		"0000000000000;", // 13 lines
+		"000000002000;",
+		"00000000000x;",
+		"+00000000001;",
+	} {
+		if got, err := parseInputReport(report); err == nil {
+			t.Errorf("parseInputReport(%q) = %v, want an error", report, got.Describe())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package bbtkv3
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OutputMask is a set of output lines, one bit per line. Written in binary, it reads in
+// the order of OutputPortNames, as the masks sent to the BBTK do: Sounder1 is the lowest bit.
+type OutputMask uint8
+
+// Output lines.
+const (
+	Sounder1 OutputMask = 1 << iota
+	Sounder2
+	TTLout1
+	TTLout2
+	ActClose1
+	ActClose2
+	ActClose3
+	ActClose4
+)
+
+// OutputLine returns the mask of the output line called name (see OutputPortNames).
+func OutputLine(name string) (OutputMask, error) {
+	i := slices.Index(OutputPortNames, name)
+	if i < 0 {
+		return 0, fmt.Errorf("unknown output line %q", name)
+	}
+	return 1 << (len(OutputPortNames) - 1 - i), nil
+}
+
+// OutputMaskFromNames returns the mask of the output lines called names.
+func OutputMaskFromNames(names ...string) (OutputMask, error) {
+	var m OutputMask
+	for _, name := range names {
+		l, err := OutputLine(name)
+		if err != nil {
+			return 0, err
+		}
+		m |= l
+	}
+	return m, nil
+}
+
+// OutputMaskFromString parses an 8-bit string such as "00000011", as written by String.
+func OutputMaskFromString(mask8 string) (OutputMask, error) {
+	if len(mask8) != 8 {
+		return 0, fmt.Errorf("output mask %q: %d characters instead of 8", mask8, len(mask8))
+	}
+	var m OutputMask
+	for i, c := range []byte(mask8) {
+		switch c {
+		case '1':
+			m |= 1 << (7 - i)
+		case '0':
+		default:
+			return 0, fmt.Errorf("output mask %q: invalid character %q at position %d (expected 0 or 1)", mask8, c, i+1)
+		}
+	}
+	return m, nil
+}
+
+// String returns the mask as the BBTK expects it: 8 bits in the order of OutputPortNames.
+func (m OutputMask) String() string {
+	return fmt.Sprintf("%08b", uint8(m))
+}
+
+// Names returns the names of the lines in the mask, in the order of OutputPortNames.
+func (m OutputMask) Names() []string {
+	var names []string
+	for _, name := range OutputPortNames {
+		if l, _ := OutputLine(name); m&l != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Has tells if all the lines of l are in the mask.
+func (m OutputMask) Has(l OutputMask) bool {
+	return m&l == l
+}
+
+// Describe lists the lines of the mask, e.g. "TTLout1+Sounder1", or "none".
+func (m OutputMask) Describe() string {
+	if m == 0 {
+		return "none"
+	}
+	return strings.Join(m.Names(), "+")
+}
+
+// SetOutputs sets the output lines (output line check, OCHK): the lines in mask are
+// activated, the others are deactivated. A sounder keeps sounding until its line is
+// deactivated.
+func (b *Bbtkv3) SetOutputs(mask OutputMask) error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if _, err := b.setOutputs(mask); err != nil {
+		return fmt.Errorf("SetOutputs: %w", err)
+	}
+	return nil
+}
+
+// setOutputs sends OCHK and mask, ArgumentDelay apart, and waits until the BBTK has processed them.
+// It returns when the mask was sent, i.e. when the lines were switched.
+func (b *Bbtkv3) setOutputs(mask OutputMask) (time.Time, error) {
+	if err := b.sendWithArgs("OCHK", mask.String()); err != nil {
+		return time.Time{}, err
+	}
+	sent := time.Now()
+	if err := b.sync("OCHK"); err != nil {
+		return time.Time{}, err
+	}
+	return sent, nil
+}
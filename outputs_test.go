@@ -0,0 +1,58 @@
+package bbtkv3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputMaskFromString(t *testing.T) {
+	tests := []struct {
+		mask8 string
+		want  OutputMask
+	}{
+		{"00000000", 0},
+		{"00000001", Sounder1},
+		{"10000000", ActClose4},
+		{"00010100", TTLout1 | ActClose1},
+		{"11111111", 0xFF},
+	}
+	for _, tt := range tests {
+		got, err := OutputMaskFromString(tt.mask8)
+		if err != nil || got != tt.want {
+			t.Errorf("OutputMaskFromString(%q) = %v, %v, want %v", tt.mask8, got, err, tt.want)
+		}
+		if s := got.String(); s != tt.mask8 {
+			t.Errorf("%q parsed and formatted as %q", tt.mask8, s)
+		}
+	}
+
+	for _, tt := range []struct {
+		mask8 string
+		msg   string // expected in the error
+	}{
+		{"", "0 characters"},
+		{"0000001", "7 characters"},
+		{"000000001", "9 characters"},
+		{"00000020", "position 7"},
+		{"x0000000", "position 1"},
+		{"0000000 ", "position 8"},
+	} {
+		_, err := OutputMaskFromString(tt.mask8)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("OutputMaskFromString(%q): got %v, want an error with %q", tt.mask8, err, tt.msg)
+		}
+	}
+}
+
+func TestOutputMaskFromNames(t *testing.T) {
+	m, err := OutputMaskFromNames("ActClose1", "Sounder2")
+	if err != nil || m != ActClose1|Sounder2 {
+		t.Errorf("got %v, %v", m, err)
+	}
+	if names := m.Names(); strings.Join(names, ",") != "ActClose1,Sounder2" {
+		t.Errorf("Names: got %v", names)
+	}
+	if _, err := OutputMaskFromNames("Sounder3"); err == nil {
+		t.Error("OutputMaskFromNames(\"Sounder3\"): no error")
+	}
+}
@@ -0,0 +1,209 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Limits checked by PulseProgram.Validate. They are NOT documented limits of the firmware, but
+// guesses: MaxPulseSteps is only meant to catch runaway programs, and can be raised if the BBTK
+// accepts longer ones (0 disables the check); MaxPulseDuration assumes that the times, sent
+// in µs, are stored as unsigned 32-bit values.
+var (
+	MaxPulseSteps    = 1000
+	MaxPulseDuration = time.Duration(1<<32-1) * time.Microsecond
+)
+
+// PulseStep is a step of a pulse-train program: after Delay, the output lines are set
+// to Mask for Duration.
+type PulseStep struct {
+	Delay    time.Duration
+	Duration time.Duration
+	Mask     OutputMask
+}
+
+// String formats the step as a row of a program sent to the BBTK: delay and duration
+// in µs, followed by the mask, e.g. "100,1000,00000001".
+func (s PulseStep) String() string {
+	return fmt.Sprintf("%d,%d,%s", s.Delay.Microseconds(), s.Duration.Microseconds(), s.Mask)
+}
+
+// PulseProgram is a pulse-train program run by the BBTK in event generation mode: its steps
+// are played in a loop until TimeLimit has elapsed (0: until the program is stopped).
+//
+// Programs are built by chaining calls, e.g. for ten 10ms TTL pulses, one every 100ms,
+// with a sound at the start:
+//
+//	p := new(bbtkv3.PulseProgram).
+//		Step(0, 50*time.Millisecond, bbtkv3.Sounder1|bbtkv3.TTLout1).
+//		Step(0, 50*time.Millisecond, 0).
+//		Pulses(bbtkv3.TTLout1, 10*time.Millisecond, 100*time.Millisecond, 9).
+//		Limit(time.Second)
+type PulseProgram struct {
+	Steps     []PulseStep
+	TimeLimit time.Duration
+}
+
+// Step appends a step to the program.
+func (p *PulseProgram) Step(delay, duration time.Duration, mask OutputMask) *PulseProgram {
+	p.Steps = append(p.Steps, PulseStep{Delay: delay, Duration: duration, Mask: mask})
+	return p
+}
+
+// Repeat appends n times the given steps to the program.
+func (p *PulseProgram) Repeat(n int, steps ...PulseStep) *PulseProgram {
+	for i := 0; i < n; i++ {
+		p.Steps = append(p.Steps, steps...)
+	}
+	return p
+}
+
+// Pulses appends count pulses of the lines of mask, lasting duration, one every period.
+func (p *PulseProgram) Pulses(mask OutputMask, duration, period time.Duration, count int) *PulseProgram {
+	return p.Repeat(count,
+		PulseStep{Duration: duration, Mask: mask},
+		PulseStep{Duration: period - duration})
+}
+
+// Limit sets the time after which the BBTK stops the program.
+func (p *PulseProgram) Limit(d time.Duration) *PulseProgram {
+	p.TimeLimit = d
+	return p
+}
+
+// Length returns the time taken by one run of the steps of the program.
+func (p *PulseProgram) Length() time.Duration {
+	var t time.Duration
+	for _, s := range p.Steps {
+		t += s.Delay + s.Duration
+	}
+	return t
+}
+
+// Validate checks that the BBTK can run the program.
+func (p *PulseProgram) Validate() error {
+	if len(p.Steps) == 0 {
+		return errors.New("pulse program: no step")
+	}
+	if MaxPulseSteps > 0 && len(p.Steps) > MaxPulseSteps {
+		return fmt.Errorf("pulse program: %d steps, more than the maximum of %d (see MaxPulseSteps)", len(p.Steps), MaxPulseSteps)
+	}
+	if p.TimeLimit < 0 || p.TimeLimit > MaxPulseDuration {
+		return fmt.Errorf("pulse program: invalid time limit %v", p.TimeLimit)
+	}
+	for i, s := range p.Steps {
+		if s.Delay < 0 || s.Delay > MaxPulseDuration {
+			return fmt.Errorf("pulse program: step %d: invalid delay %v", i+1, s.Delay)
+		}
+		if s.Duration < 0 || s.Duration > MaxPulseDuration {
+			return fmt.Errorf("pulse program: step %d: invalid duration %v", i+1, s.Duration)
+		}
+		if s.Delay%time.Microsecond != 0 || s.Duration%time.Microsecond != 0 {
+			return fmt.Errorf("pulse program: step %d: times must be whole numbers of µs", i+1)
+		}
+	}
+	if p.Length() == 0 {
+		return errors.New("pulse program: all the steps are empty")
+	}
+	return nil
+}
+
+// UploadPulseProgram validates p and sends it to the BBTK (PRPT, TIML, the steps, then PCPT).
+func (b *Bbtkv3) UploadPulseProgram(p *PulseProgram) error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.uploadPulseProgram(p)
+}
+
+func (b *Bbtkv3) uploadPulseProgram(p *PulseProgram) error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("UploadPulseProgram: %w", err)
+	}
+
+	if err := b.send("PRPT"); err != nil {
+		return fmt.Errorf("UploadPulseProgram: %w", err)
+	}
+	args := []string{fmt.Sprintf("%d", p.TimeLimit.Microseconds())}
+	for _, s := range p.Steps {
+		args = append(args, s.String())
+	}
+	if err := b.sendWithArgs("TIML", args...); err != nil {
+		return fmt.Errorf("UploadPulseProgram: %w", err)
+	}
+	time.Sleep(ArgumentDelay)
+	if err := b.send("PCPT"); err != nil {
+		return fmt.Errorf("UploadPulseProgram: %w", err)
+	}
+	if err := b.sync("PCPT"); err != nil {
+		return fmt.Errorf("UploadPulseProgram: %w", err)
+	}
+	return nil
+}
+
+// StartPulseProgram makes the BBTK run the program uploaded with UploadPulseProgram (RUPT).
+// On the BBTKv3, any command sent before StopPulseProgram, e.g. by another goroutine, stops
+// the program; RunPulseProgram makes the other goroutines wait instead.
+func (b *Bbtkv3) StartPulseProgram() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.startPulseProgram()
+}
+
+func (b *Bbtkv3) startPulseProgram() error {
+	if err := b.send("RUPT"); err != nil {
+		return fmt.Errorf("StartPulseProgram: %w", err)
+	}
+	b.logger.Info("pulse program started")
+	return nil
+}
+
+// StopPulseProgram stops the running program, with a serial break on the BBTKv2 (which requires
+// a Transport able to send one, as a serial port is) or a command on the BBTKv3, and waits until
+// the BBTK answers commands again.
+func (b *Bbtkv3) StopPulseProgram() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	return b.stopPulseProgram()
+}
+
+func (b *Bbtkv3) stopPulseProgram() error {
+	if err := b.leaveMode("RUPT"); err != nil {
+		return fmt.Errorf("StopPulseProgram: %w", err)
+	}
+	b.logger.Info("pulse program stopped")
+	return nil
+}
+
+// RunPulseProgram uploads and runs p, until its time limit has elapsed or, if it has none,
+// until ctx is cancelled. The other goroutines using b wait until the program has stopped.
+func (b *Bbtkv3) RunPulseProgram(ctx context.Context, p *PulseProgram) error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.uploadPulseProgram(p); err != nil {
+		return err
+	}
+	if err := b.startPulseProgram(); err != nil {
+		return err
+	}
+
+	if p.TimeLimit > 0 {
+		select {
+		case <-time.After(p.TimeLimit):
+			if err := b.sync("RUPT"); err != nil {
+				return fmt.Errorf("RunPulseProgram: %w", err)
+			}
+			return nil
+		case <-ctx.Done():
+		}
+	} else {
+		<-ctx.Done()
+	}
+
+	return b.stopPulseProgram()
+}
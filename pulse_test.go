@@ -0,0 +1,53 @@
+package bbtkv3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPulseProgramValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     *PulseProgram
+		valid bool
+	}{
+		{"pulses", new(PulseProgram).Pulses(TTLout1, 10*time.Millisecond, 100*time.Millisecond, 10).Limit(time.Second), true},
+		{"no step", new(PulseProgram), false},
+		{"empty steps", new(PulseProgram).Step(0, 0, TTLout1), false},
+		{"negative delay", new(PulseProgram).Step(-time.Millisecond, time.Millisecond, TTLout1), false},
+		{"negative duration", new(PulseProgram).Step(0, -time.Millisecond, TTLout1), false},
+		{"sub-µs duration", new(PulseProgram).Step(0, 1500*time.Nanosecond, TTLout1), false},
+		{"longest duration", new(PulseProgram).Step(0, MaxPulseDuration, TTLout1), true},
+		{"duration too long", new(PulseProgram).Step(0, MaxPulseDuration+time.Microsecond, TTLout1), false},
+		{"delay too long", new(PulseProgram).Step(MaxPulseDuration+time.Microsecond, time.Millisecond, TTLout1), false},
+		{"negative time limit", new(PulseProgram).Step(0, time.Millisecond, TTLout1).Limit(-time.Second), false},
+		{"time limit too long", new(PulseProgram).Step(0, time.Millisecond, TTLout1).Limit(MaxPulseDuration + time.Microsecond), false},
+		{"most steps", new(PulseProgram).Pulses(TTLout1, time.Millisecond, 2*time.Millisecond, MaxPulseSteps/2), true},
+		{"too many steps", new(PulseProgram).Pulses(TTLout1, time.Millisecond, 2*time.Millisecond, MaxPulseSteps/2+1), false},
+	}
+	for _, tt := range tests {
+		if err := tt.p.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestPulseProgramStepLimitDisabled(t *testing.T) {
+	defer func(n int) { MaxPulseSteps = n }(MaxPulseSteps)
+
+	p := new(PulseProgram).Pulses(TTLout1, time.Millisecond, 2*time.Millisecond, 2000)
+	if err := p.Validate(); err == nil {
+		t.Errorf("%d steps: no error with MaxPulseSteps = %d", len(p.Steps), MaxPulseSteps)
+	}
+	MaxPulseSteps = 0
+	if err := p.Validate(); err != nil {
+		t.Errorf("%d steps: %v with MaxPulseSteps = 0", len(p.Steps), err)
+	}
+}
+
+func TestPulseStepString(t *testing.T) {
+	s := PulseStep{Delay: 100 * time.Microsecond, Duration: time.Millisecond, Mask: Sounder1 | ActClose4}
+	if got, want := s.String(), "100,1000,10000001"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
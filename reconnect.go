@@ -0,0 +1,192 @@
+package bbtkv3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// ReconnectTimeout is how long the search for a lost BBTK goes on, e.g. while the USB
+// cable is plugged back in or the box restarts.
+var ReconnectTimeout = 30 * time.Second
+
+// reconnectPollInterval is how often the serial ports are listed while looking for a lost BBTK.
+const reconnectPollInterval = 500 * time.Millisecond
+
+// ReconnectEvent reports an attempt to reconnect to the BBTK after the port was lost.
+type ReconnectEvent struct {
+	Cause   error         // error which revealed the loss of the port (nil for a call to Reconnect)
+	Port    string        // port at which the BBTK was found again
+	Elapsed time.Duration // time spent reconnecting
+	Err     error         // nil if the BBTK is connected again
+}
+
+// SetAutoReconnect enables or disables the automatic reconnection of the BBTK when its port
+// is lost (e.g. the USB cable was unplugged or the box was reset). The call which hits the
+// loss still fails, with an error matching ErrPortLost, but before returning it looks for
+// the same BBTK (by its USB serial number, as the port name may change), reopens it, redoes
+// the CONN handshake and sets again the last thresholds and smoothing mask set through b.
+// notify, if not nil, is called with the outcome of each attempt, so that the caller can
+// decide whether to retry what was interrupted. It is called once b is unlocked, before the
+// call which hit the loss returns, so it may call the methods of b.
+//
+// Reconnection is only possible for the objects created by NewBbtkv3: on the others, the loss
+// of the port is only reported by the error.
+func (b *Bbtkv3) SetAutoReconnect(enabled bool, notify func(ReconnectEvent)) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.autoReconnect = enabled
+	b.onReconnect = notify
+}
+
+// Reconnect closes the port to the BBTK, then reopens and reinitializes it as SetAutoReconnect
+// does when the port is lost. It waits at most ReconnectTimeout for the BBTK to reappear.
+func (b *Bbtkv3) Reconnect(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	if err := b.reconnect(ctx, nil); err != nil {
+		return fmt.Errorf("Reconnect: %w", err)
+	}
+	return nil
+}
+
+// checkLost is applied to the errors of the I/O on the port: if the port was lost and automatic
+// reconnection is enabled, the BBTK is reconnected before err is returned. The caller holds b.mu,
+// so that the other goroutines wait for the reconnection.
+func (b *Bbtkv3) checkLost(err error) error {
+	if b.autoReconnect && !b.reconnecting && b.portAddress != "" && errors.Is(err, ErrPortLost) {
+		b.reconnect(context.Background(), err)
+	}
+	return err
+}
+
+func (b *Bbtkv3) reconnect(ctx context.Context, cause error) error {
+	b.reconnecting = true
+	defer func() { b.reconnecting = false }()
+
+	start := time.Now()
+	b.logger.Warn("reconnecting to the BBTK", "cause", cause)
+
+	err := b.reopen(ctx)
+	if err == nil {
+		err = b.restore()
+	}
+
+	e := ReconnectEvent{Cause: cause, Port: b.portAddress, Elapsed: time.Since(start), Err: err}
+	if err != nil {
+		b.logger.Error("reconnection failed", "err", err, "elapsed", e.Elapsed)
+	} else {
+		b.logger.Warn("reconnected", "port", e.Port, "elapsed", e.Elapsed)
+	}
+	if b.onReconnect != nil {
+		b.reconnectEvents = append(b.reconnectEvents, e) // delivered by unlock
+	}
+	return err
+}
+
+// unlock releases b.mu, then calls the callback of SetAutoReconnect with the events of the
+// reconnections which occurred while it was held.
+func (b *Bbtkv3) unlock() {
+	events, notify := b.reconnectEvents, b.onReconnect
+	b.reconnectEvents = nil
+	b.mu.Unlock()
+
+	for _, e := range events {
+		notify(e)
+	}
+}
+
+// reopen closes the port and opens it again, once the BBTK is back.
+func (b *Bbtkv3) reopen(ctx context.Context) error {
+	if b.portAddress == "" {
+		return errors.New("the transport of the BBTK cannot be reopened")
+	}
+
+	b.port.Close() // the port is most likely gone already
+
+	ctx, cancel := context.WithTimeout(ctx, ReconnectTimeout)
+	defer cancel()
+
+	for {
+		port, err := b.locate()
+		if err == nil {
+			var t Transport
+			if t, err = OpenSerial(port, b.baudrate); err == nil {
+				b.attach(t)
+				b.portAddress = port
+				return nil
+			}
+		}
+		b.logger.Debug("BBTK not available yet", "err", err)
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%w: BBTK not found again within %v (%v)", ErrTimeout, ReconnectTimeout, err)
+			}
+			return ctx.Err()
+		case <-time.After(reconnectPollInterval):
+		}
+	}
+}
+
+// locate returns the port of the BBTK: the one of the USB device with its serial number if it
+// is known, otherwise the port it was opened at, if it exists.
+func (b *Bbtkv3) locate() (string, error) {
+	details, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return "", err
+	}
+	for _, d := range details {
+		if b.serialNumber != "" && d.IsUSB && d.SerialNumber == b.serialNumber {
+			return d.Name, nil
+		}
+		if b.serialNumber == "" && d.Name == b.portAddress {
+			return d.Name, nil
+		}
+	}
+	if b.serialNumber != "" {
+		return "", fmt.Errorf("no USB device with serial number %q", b.serialNumber)
+	}
+	return "", fmt.Errorf("no port %s", b.portAddress)
+}
+
+// restore redoes the handshake with the BBTK and sets again the last thresholds and
+// smoothing mask, which are lost when the box is reset.
+func (b *Bbtkv3) restore() error {
+	b.resetSerialBuffers()
+	if err := b.connect(time.Now().Add(ConnectTimeout)); err != nil {
+		return err
+	}
+	if b.smoothing != nil {
+		if err := b.setSmoothing(*b.smoothing); err != nil {
+			return err
+		}
+	}
+	if b.thresholds != nil {
+		if err := b.setThresholds(*b.thresholds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usbSerialNumber returns the serial number of the USB device providing port, or ""
+// if it is not a USB device or its identity is unknown.
+func usbSerialNumber(port string) string {
+	details, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return ""
+	}
+	for _, d := range details {
+		if d.Name == port && d.IsUSB {
+			return d.SerialNumber
+		}
+	}
+	return ""
+}
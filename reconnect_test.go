@@ -0,0 +1,36 @@
+package bbtkv3
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectEventsDeliveredUnlocked(t *testing.T) {
+	b := NewBbtkv3FromTransport(NewReplayer(nil), nil)
+
+	var got []ReconnectEvent
+	b.SetAutoReconnect(true, func(e ReconnectEvent) {
+		b.Firmware() // would deadlock if b were still locked
+		got = append(got, e)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.mu.Lock()
+		b.reconnectEvents = append(b.reconnectEvents, ReconnectEvent{Port: "COM4"}, ReconnectEvent{Port: "COM5"})
+		b.unlock()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the callback deadlocked")
+	}
+	if len(got) != 2 || got[0].Port != "COM4" || got[1].Port != "COM5" {
+		t.Errorf("got %+v", got)
+	}
+	if len(b.reconnectEvents) != 0 {
+		t.Error("events delivered twice")
+	}
+}
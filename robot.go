@@ -0,0 +1,168 @@
+package bbtkv3
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Latency gives the latencies of the responses of a Robot.
+type Latency interface {
+	Next() time.Duration
+}
+
+// FixedLatency is a constant latency.
+type FixedLatency time.Duration
+
+func (l FixedLatency) Next() time.Duration { return time.Duration(l) }
+
+// UniformLatency draws latencies uniformly between Min and Max.
+type UniformLatency struct {
+	Min, Max time.Duration
+}
+
+func (l UniformLatency) Next() time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + rand.N(l.Max-l.Min)
+}
+
+// NormalLatency draws latencies from a normal distribution, truncated at Min.
+type NormalLatency struct {
+	Mean, SD, Min time.Duration
+}
+
+func (l NormalLatency) Next() time.Duration {
+	return max(l.Min, l.Mean+time.Duration(rand.NormFloat64()*float64(l.SD)))
+}
+
+// Robot makes the BBTK act as a participant: each time the Input line becomes active
+// (e.g. a photodiode detects a stimulus), the Output lines (e.g. ActClose1, wired to a key
+// of a keyboard or a button box) are activated after a latency drawn from Latency,
+// for Hold.
+//
+// The firmware has no documented stimulus-response mode, so the robot runs on the host:
+// it watches the input in input check mode (ICHK), leaves it when the stimulus is detected,
+// then sets the outputs (OCHK) at the scheduled time. Leaving input check mode takes some
+// milliseconds, so latencies shorter than that are late (see RobotResponse.Late); a
+// capture by a second BBTK gives the exact timing.
+type Robot struct {
+	Input   string
+	Output  OutputMask
+	Latency Latency
+	Hold    time.Duration
+	Trials  int // number of responses to give (0: until the context is cancelled)
+
+	// OnResponse, if not nil, is called after each response.
+	OnResponse func(RobotResponse)
+}
+
+// RobotResponse records a response of a Robot. Times are those of the host.
+type RobotResponse struct {
+	Trial     int           // from 1
+	Stimulus  time.Time     // when the activation of the input was reported by the BBTK
+	Commanded time.Duration // latency drawn for the response
+	Actual    time.Duration // time between Stimulus and the activation of the outputs
+}
+
+// Late tells how much later than commanded the response was given.
+func (r RobotResponse) Late() time.Duration {
+	return r.Actual - r.Commanded
+}
+
+// RunRobot runs r until it has given r.Trials responses or ctx is cancelled,
+// and returns the responses given. After a response, the input must be seen inactive before
+// a new stimulus is responded to. When ctx is cancelled while the outputs are active, they are
+// reset before RunRobot returns.
+func (b *Bbtkv3) RunRobot(ctx context.Context, r Robot) ([]RobotResponse, error) {
+	input, ok := LineByName(r.Input)
+	if !ok || input&InputLines == 0 {
+		return nil, fmt.Errorf("RunRobot: unknown input line %q", r.Input)
+	}
+	if r.Output == 0 {
+		return nil, fmt.Errorf("RunRobot: no output line")
+	}
+	if r.Latency == nil {
+		r.Latency = FixedLatency(0)
+	}
+
+	var responses []RobotResponse
+	for trial := 1; r.Trials == 0 || trial <= r.Trials; trial++ {
+		stimulus, err := b.waitForOnset(ctx, input, trial == 1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return responses, nil
+			}
+			return responses, fmt.Errorf("RunRobot: %w", err)
+		}
+
+		// OCHK is sent ArgumentDelay before the mask, which switches the lines
+		commanded := r.Latency.Next()
+		select {
+		case <-time.After(time.Until(stimulus.Add(commanded - ArgumentDelay))):
+		case <-ctx.Done():
+			return responses, nil
+		}
+		b.mu.Lock()
+		response, err := b.setOutputs(r.Output)
+		b.unlock()
+		if err != nil {
+			return responses, fmt.Errorf("RunRobot: %w", err)
+		}
+		held := true
+		select {
+		case <-time.After(r.Hold):
+		case <-ctx.Done():
+			held = false
+		}
+		if err := b.SetOutputs(0); err != nil {
+			return responses, fmt.Errorf("RunRobot: %w", err)
+		}
+
+		resp := RobotResponse{Trial: trial, Stimulus: stimulus, Commanded: commanded, Actual: response.Sub(stimulus)}
+		b.logger.Info("robot response", "trial", trial, "commanded", commanded, "actual", resp.Actual)
+		responses = append(responses, resp)
+		if r.OnResponse != nil {
+			r.OnResponse(resp)
+		}
+		if !held {
+			return responses, nil
+		}
+	}
+	return responses, nil
+}
+
+// waitForOnset watches the input line until it becomes active, and returns when this was reported.
+// Unless armed, the line must first be reported inactive, so that a stimulus still on since
+// the previous response is not taken for a new one.
+func (b *Bbtkv3) waitForOnset(ctx context.Context, line LineMask, armed bool) (time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m, err := b.MonitorInputs(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var onset time.Time
+	for s := range m.States() {
+		if !s.Lines.Has(line) {
+			armed = true
+		} else if armed {
+			onset = s.Time
+			cancel()
+			break
+		}
+	}
+	for range m.States() { // let the monitor stop
+	}
+	if err := m.Wait(); err != nil {
+		return time.Time{}, err
+	}
+	if onset.IsZero() {
+		return time.Time{}, ctx.Err()
+	}
+	return onset, nil
+}
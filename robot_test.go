@@ -0,0 +1,72 @@
+package bbtkv3_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/sim"
+)
+
+// connectSim returns a Bbtkv3 connected to a simulator playing scenario.
+func connectSim(t *testing.T, scenario sim.Scenario) (*bbtkv3.Bbtkv3, *sim.Device) {
+	t.Helper()
+	dev := sim.New(scenario)
+	b := bbtkv3.NewBbtkv3FromTransport(dev.Pipe(), nil)
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Disconnect() })
+	return b, dev
+}
+
+func TestRobotLongStimulus(t *testing.T) {
+	// A stimulus of 1s, then a short one: one response each.
+	b, _ := connectSim(t, append(
+		sim.Pulses("Opto1", 100*time.Millisecond, time.Second, 0, 1),
+		sim.Pulses("Opto1", 1400*time.Millisecond, 100*time.Millisecond, 0, 1)...))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	responses, err := b.RunRobot(ctx, bbtkv3.Robot{
+		Input:   "Opto1",
+		Output:  bbtkv3.ActClose1,
+		Latency: bbtkv3.FixedLatency(50 * time.Millisecond),
+		Hold:    50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2: %+v", len(responses), responses)
+	}
+	if gap := responses[1].Stimulus.Sub(responses[0].Stimulus); gap < time.Second {
+		t.Errorf("second stimulus %v after the first one, want 1.3s", gap)
+	}
+}
+
+func TestRobotCancelDuringHold(t *testing.T) {
+	b, dev := connectSim(t, sim.Pulses("Opto1", 100*time.Millisecond, 50*time.Millisecond, 0, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	responses, err := b.RunRobot(ctx, bbtkv3.Robot{
+		Input:  "Opto1",
+		Output: bbtkv3.ActClose1,
+		Hold:   time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RunRobot returned %v after the start, long after the cancellation", elapsed)
+	}
+	if len(responses) != 1 {
+		t.Errorf("got %d responses, want 1", len(responses))
+	}
+	if got := dev.Outputs(); got != "00000000" {
+		t.Errorf("outputs left at %s", got)
+	}
+}
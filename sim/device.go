@@ -0,0 +1,433 @@
+// Software emulation of a Black Box ToolKit v3
+// Author: Christophe Pallier <christophe@pallier.org>
+// LICENSE: GPL-3.0
+
+// Package sim emulates a BBTKv3 at the other end of the wire.
+//
+// A Device answers the serial commands used by the bbtkv3 package
+// (CONN, ECHO, FIRM, GEPV, SEPV, SMOO, SPIE, AJPV, ABOU, FLUS, DSCM, TIML, RUDS, ICHK, OCHK, PRPT, PCPT, RUPT)
+// and, during a capture, produces SDAT...EDAT data generated from a Scenario
+// of input line transitions. In input check mode (ICHK), the Scenario is played from
+// the reception of the first ICHK, and goes on across the next ones, as real sensors would. Pulse-train programs (PRPT) change the state of the
+// output lines, as reported by Outputs. This permits to develop and test programs driving
+// the BBTK without a box on the desk:
+//
+//	dev := sim.New(sim.Pulses("Opto1", time.Second, 50*time.Millisecond, time.Second, 5))
+//	b := bbtkv3.NewBbtkv3FromTransport(dev.Pipe(), nil)
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+)
+
+// DefaultFirmware is the answer of a simulated device to the FIRM command.
+const DefaultFirmware = "BBTKv3 Firmware v3.1.2;"
+
+// SamplePeriod is the interval between two samples of the input lines, used to fill
+// the "number of samples" field of the capture header.
+const SamplePeriod = 250 * time.Microsecond
+
+// Device is a simulated BBTKv3.
+// The exported fields configure the device; they must not be modified while it is served.
+type Device struct {
+	Firmware string   // answer to FIRM
+	Scenario Scenario // what the input lines do during a capture
+
+	// TimeScale speeds up (> 1) or slows down (< 1) time during captures.
+	TimeScale float64
+
+	// AdjustDelay is how long the simulated user takes to adjust the thresholds after AJPV.
+	AdjustDelay time.Duration
+
+	// EraseDelay is how long SPIE takes to clear the memory.
+	EraseDelay time.Duration
+
+	Logger *slog.Logger
+
+	mu          sync.Mutex
+	thresholds  bbtkv3.Thresholds
+	smoothing   string
+	outputs     string
+	formatted   bool
+	mode        string
+	timeLimit   time.Duration
+	capturing   bool
+	pending     *pendingArgs
+	program     []string // rows of the pulse-train program, while it is received
+	programming bool
+	running     chan struct{} // closed to leave input check or pulse-train mode
+	inputStart  time.Time     // when the Scenario of input check mode started (first ICHK)
+
+	wmu sync.Mutex // serializes writes to the host
+}
+
+// pendingArgs collects the lines following a multi-line command (e.g. SEPV and its 8 values).
+type pendingArgs struct {
+	command string
+	want    int
+	args    []string
+	done    func(args []string)
+}
+
+// New creates a simulated device which will play scenario during captures.
+func New(scenario Scenario) *Device {
+	return &Device{
+		Firmware:    DefaultFirmware,
+		Scenario:    scenario,
+		TimeScale:   1,
+		AdjustDelay: 2 * time.Second,
+		EraseDelay:  200 * time.Millisecond,
+		thresholds: bbtkv3.Thresholds{
+			Mic1: 63, Mic2: 63, Sounder1: 63, Sounder2: 63,
+			Opto1: 63, Opto2: 63, Opto3: 63, Opto4: 63,
+		},
+		smoothing: "11111111",
+		outputs:   "00000000",
+	}
+}
+
+// Thresholds returns the thresholds currently set on the device.
+func (d *Device) Thresholds() bbtkv3.Thresholds {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.thresholds
+}
+
+// Smoothing returns the current smoothing mask, as sent with SMOO.
+func (d *Device) Smoothing() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.smoothing
+}
+
+// Outputs returns the state of the output lines, as last set with OCHK.
+func (d *Device) Outputs() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.outputs
+}
+
+func (d *Device) logger() *slog.Logger {
+	if d.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return d.Logger
+}
+
+// scaled converts a duration of simulated time into real time.
+func (d *Device) scaled(t time.Duration) time.Duration {
+	if d.TimeScale <= 0 {
+		return t
+	}
+	return time.Duration(float64(t) / d.TimeScale)
+}
+
+// Serve answers the commands read from rw until it is closed.
+func (d *Device) Serve(rw io.ReadWriter) error {
+	r := bufio.NewReader(rw)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		d.handle(rw, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// send writes the lines to the host, each terminated by '\n'.
+func (d *Device) send(w io.Writer, lines ...string) {
+	d.wmu.Lock()
+	defer d.wmu.Unlock()
+	for _, l := range lines {
+		if _, err := io.WriteString(w, l+"\n"); err != nil {
+			d.logger().Warn("write failed", "err", err)
+			return
+		}
+	}
+}
+
+// expect makes the next n lines be passed to done rather than interpreted as commands.
+func (d *Device) expect(command string, n int, done func(args []string)) {
+	d.pending = &pendingArgs{command: command, want: n, done: done}
+}
+
+func (d *Device) handle(w io.Writer, line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if p := d.pending; p != nil {
+		p.args = append(p.args, line)
+		if len(p.args) == p.want {
+			d.pending = nil
+			p.done(p.args)
+		}
+		return
+	}
+
+	if d.running != nil {
+		// As on the BBTKv3, on which breaks are not used, any line ends the input
+		// check or the pulse-train program (a pty cannot carry breaks anyway).
+		d.stop()
+	}
+
+	if d.programming {
+		switch line {
+		case "TIML":
+		case "PCPT":
+			d.programming = false
+			d.logger().Info("pulse program received", "steps", len(d.program), "limit", d.timeLimit)
+			return
+		default:
+			d.program = append(d.program, line)
+			return
+		}
+	}
+
+	d.logger().Debug("command", "cmd", line)
+
+	if d.capturing {
+		d.logger().Warn("command ignored during capture", "cmd", line)
+		return
+	}
+
+	switch line {
+	case "":
+	case "CONN":
+		d.send(w, "BBTK;")
+	case "ECHO":
+		d.send(w, "ECHO")
+	case "FIRM":
+		d.send(w, d.Firmware)
+	case "ABOU", "FLUS":
+	case "GEPV":
+		d.send(w, d.thresholds.ToString()+";")
+	case "SEPV":
+		d.expect(line, 8, func(args []string) {
+			t, err := bbtkv3.ThresholdsFromString(strings.Join(args, ","))
+			if err != nil {
+				d.logger().Warn("SEPV: invalid thresholds", "args", args, "err", err)
+				return
+			}
+			d.thresholds = t
+		})
+	case "SMOO":
+		d.expect(line, 1, func(args []string) {
+			mask := args[0]
+			if len(mask) != 8 || strings.Trim(mask, "01") != "" {
+				d.logger().Warn("SMOO: invalid mask", "mask", mask)
+				return
+			}
+			d.smoothing = mask
+		})
+	case "OCHK":
+		d.expect(line, 1, func(args []string) {
+			mask := args[0]
+			if len(mask) != 8 || strings.Trim(mask, "01") != "" {
+				d.logger().Warn("OCHK: invalid mask", "mask", mask)
+				return
+			}
+			d.outputs = mask
+			d.logger().Info("outputs set", "mask", mask)
+		})
+	case "SPIE":
+		if d.formatted {
+			d.send(w, "ESEC;")
+		} else {
+			d.send(w, "FRMT;")
+			d.formatted = true
+		}
+		go func() {
+			time.Sleep(d.EraseDelay)
+			d.send(w, "DONE;")
+		}()
+	case "AJPV":
+		go func() {
+			time.Sleep(d.AdjustDelay)
+			d.send(w, "Done;")
+		}()
+	case "DSCM":
+		d.mode = line
+	case "TIML":
+		d.expect(line, 1, func(args []string) {
+			us, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || us < 0 {
+				d.logger().Warn("TIML: invalid time limit", "arg", args[0])
+				return
+			}
+			d.timeLimit = time.Duration(us) * time.Microsecond
+		})
+	case "RUDS":
+		if d.mode != "DSCM" {
+			d.logger().Warn("RUDS outside of DSCM mode")
+			return
+		}
+		d.capturing = true
+		go d.capture(w, d.timeLimit)
+	case "ICHK":
+		if d.inputStart.IsZero() {
+			d.inputStart = time.Now()
+		}
+		d.running = make(chan struct{})
+		go d.inputCheck(w, d.inputStart, d.running)
+	case "PRPT":
+		d.programming = true
+		d.program = nil
+	case "RUPT":
+		steps, err := parseProgram(d.program)
+		if err != nil {
+			d.logger().Warn("RUPT: invalid program", "err", err)
+			return
+		}
+		d.running = make(chan struct{})
+		go d.runProgram(steps, d.timeLimit, d.running)
+	default:
+		d.logger().Warn("unknown command", "cmd", line)
+	}
+}
+
+// capture waits for the duration of the capture, then sends the data.
+func (d *Device) capture(w io.Writer, duration time.Duration) {
+	d.logger().Info("capture started", "duration", duration)
+	time.Sleep(d.scaled(duration))
+
+	recs := d.Scenario.records(duration)
+	lines := make([]string, 0, len(recs)+6)
+	lines = append(lines,
+		"",
+		"SDAT;",
+		fmt.Sprintf("%d;", len(recs)),
+		fmt.Sprintf("%d;", duration.Microseconds()),
+		fmt.Sprintf("%d;", duration/SamplePeriod),
+	)
+	for _, r := range recs {
+		lines = append(lines, r+";")
+	}
+	lines = append(lines, "EDAT;")
+	d.send(w, lines...)
+
+	d.mu.Lock()
+	d.capturing = false
+	d.mode = ""
+	d.mu.Unlock()
+	d.logger().Info("capture done", "events", len(recs))
+}
+
+// Break receives a serial break, which ends the input check mode or the pulse-train program.
+func (d *Device) Break() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running != nil {
+		d.stop()
+	}
+}
+
+func (d *Device) stop() {
+	close(d.running)
+	d.running = nil
+	d.logger().Info("stopped")
+}
+
+// inputCheck reports the state of the input lines, as the scenario started at start changes
+// them, until stop is closed.
+func (d *Device) inputCheck(w io.Writer, start time.Time, stop chan struct{}) {
+	d.logger().Info("input check started")
+	n := len(bbtkv3.InputPortNames)
+
+	// the steps already past only give the state reported first
+	steps := d.Scenario.timeline()
+	for len(steps) > 1 && !start.Add(d.scaled(steps[1].At)).After(time.Now()) {
+		steps = steps[1:]
+	}
+
+	prev := ""
+	for _, st := range steps {
+		if st.State[:n] == prev {
+			continue
+		}
+		select {
+		case <-time.After(time.Until(start.Add(d.scaled(st.At)))):
+		case <-stop:
+			return
+		}
+		d.send(w, st.State[:n]+";")
+		prev = st.State[:n]
+	}
+}
+
+// parseProgram parses the rows of a pulse-train program ("<delay>,<duration>,<mask>", times in µs).
+func parseProgram(rows []string) ([]bbtkv3.PulseStep, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no step")
+	}
+	steps := make([]bbtkv3.PulseStep, len(rows))
+	for i, row := range rows {
+		f := strings.Split(row, ",")
+		if len(f) != 3 {
+			return nil, fmt.Errorf("invalid row %q", row)
+		}
+		delay, err1 := strconv.ParseUint(f[0], 10, 32)
+		duration, err2 := strconv.ParseUint(f[1], 10, 32)
+		mask, err3 := bbtkv3.OutputMaskFromString(f[2])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("invalid row %q", row)
+		}
+		steps[i] = bbtkv3.PulseStep{
+			Delay:    time.Duration(delay) * time.Microsecond,
+			Duration: time.Duration(duration) * time.Microsecond,
+			Mask:     mask,
+		}
+	}
+	return steps, nil
+}
+
+// runProgram plays the steps of a pulse-train program in a loop on the output lines,
+// until limit (0: no limit) or until stop is closed.
+func (d *Device) runProgram(steps []bbtkv3.PulseStep, limit time.Duration, stop chan struct{}) {
+	d.logger().Info("pulse program started")
+	start := time.Now()
+	var at time.Duration
+
+	wait := func(t time.Duration) bool {
+		if limit > 0 && t > limit {
+			t = limit
+		}
+		select {
+		case <-time.After(time.Until(start.Add(d.scaled(t)))):
+			return limit == 0 || t < limit
+		case <-stop:
+			return false
+		}
+	}
+	set := func(mask bbtkv3.OutputMask) {
+		d.mu.Lock()
+		d.outputs = mask.String()
+		d.mu.Unlock()
+	}
+
+	defer set(0)
+	for {
+		for _, s := range steps {
+			at += s.Delay
+			if !wait(at) {
+				return
+			}
+			set(s.Mask)
+			at += s.Duration
+			if !wait(at) {
+				return
+			}
+		}
+	}
+}
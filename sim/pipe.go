@@ -0,0 +1,159 @@
+package sim
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// buffer is one direction of an in-memory serial line.
+type buffer struct {
+	mu     sync.Mutex
+	data   bytes.Buffer
+	closed bool
+	ready  chan struct{} // signalled when data arrives or the buffer is closed
+}
+
+func newBuffer() *buffer {
+	return &buffer{ready: make(chan struct{}, 1)}
+}
+
+func (b *buffer) signal() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (b *buffer) write(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	n, _ := b.data.Write(p)
+	b.mu.Unlock()
+	b.signal()
+	return n, nil
+}
+
+// read waits at most timeout for data. A negative timeout waits forever.
+// Like a serial port, it returns (0, nil) when the timeout expires.
+func (b *buffer) read(p []byte, timeout time.Duration) (int, error) {
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		b.mu.Lock()
+		if b.data.Len() > 0 {
+			n, _ := b.data.Read(p)
+			b.mu.Unlock()
+			return n, nil
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return 0, io.EOF
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.ready:
+		case <-expired:
+			return 0, nil
+		}
+	}
+}
+
+func (b *buffer) reset() {
+	b.mu.Lock()
+	b.data.Reset()
+	b.mu.Unlock()
+}
+
+func (b *buffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.signal()
+}
+
+// Conn is the host end of an in-memory serial line connected to a simulated BBTK.
+// It implements bbtkv3.Transport.
+type Conn struct {
+	rx, tx *buffer
+	dev    *Device
+
+	mu      sync.Mutex
+	timeout time.Duration
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	timeout := c.timeout
+	c.mu.Unlock()
+	return c.rx.read(p, timeout)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.tx.write(p)
+}
+
+// Close closes the line; the simulated device stops serving it.
+func (c *Conn) Close() error {
+	c.rx.close()
+	c.tx.close()
+	return nil
+}
+
+// Break sends a serial break to the device.
+func (c *Conn) Break(d time.Duration) error {
+	c.dev.Break()
+	return nil
+}
+
+func (c *Conn) ResetInputBuffer() error {
+	c.rx.reset()
+	return nil
+}
+
+func (c *Conn) ResetOutputBuffer() error {
+	c.tx.reset()
+	return nil
+}
+
+// SetReadTimeout sets how long Read waits for data. A negative value waits forever.
+func (c *Conn) SetReadTimeout(t time.Duration) error {
+	c.mu.Lock()
+	c.timeout = t
+	c.mu.Unlock()
+	return nil
+}
+
+// deviceEnd is the device end of the in-memory line.
+type deviceEnd struct {
+	rx, tx *buffer
+}
+
+func (e deviceEnd) Read(p []byte) (int, error) {
+	return e.rx.read(p, -1)
+}
+
+func (e deviceEnd) Write(p []byte) (int, error) {
+	return e.tx.write(p)
+}
+
+// Pipe returns the host end of a new in-memory serial line, the device end
+// of which is served by d in the background until the line is closed.
+func (d *Device) Pipe() *Conn {
+	hostToDevice := newBuffer()
+	deviceToHost := newBuffer()
+
+	go d.Serve(deviceEnd{rx: hostToDevice, tx: deviceToHost})
+
+	return &Conn{rx: deviceToHost, tx: hostToDevice, dev: d, timeout: -1}
+}
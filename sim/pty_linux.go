@@ -0,0 +1,95 @@
+//go:build linux
+
+package sim
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// PTY is a Linux pseudo-terminal. Programs open the slave side (Path) as if it were
+// the serial port of a real BBTK, while the device serves the master side.
+type PTY struct {
+	Path   string // e.g. /dev/pts/3
+	master *os.File
+	slave  *os.File
+}
+
+// OpenPTY creates a new pseudo-terminal in raw mode.
+func OpenPTY() (*PTY, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("OpenPTY: %w", err)
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("OpenPTY: unlocking pty: %w", err)
+	}
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("OpenPTY: getting pty number: %w", err)
+	}
+	path := fmt.Sprintf("/dev/pts/%d", n)
+
+	// We keep the slave open ourselves: otherwise reading the master fails
+	// with EIO whenever no client has the port open.
+	slave, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("OpenPTY: %w", err)
+	}
+	if err := makeRaw(int(slave.Fd())); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("OpenPTY: %w", err)
+	}
+
+	return &PTY{Path: path, master: master, slave: slave}, nil
+}
+
+// makeRaw disables echo and all input/output processing on the terminal fd.
+func makeRaw(fd int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
+
+func (p *PTY) Read(b []byte) (int, error) {
+	return p.master.Read(b)
+}
+
+func (p *PTY) Write(b []byte) (int, error) {
+	return p.master.Write(b)
+}
+
+// Close removes the pseudo-terminal.
+func (p *PTY) Close() error {
+	p.slave.Close()
+	return p.master.Close()
+}
+
+// ServePTY creates a pseudo-terminal and serves it in the background.
+// It returns the pty, the Path of which can be given to any program expecting
+// the serial port of a BBTK.
+func (d *Device) ServePTY() (*PTY, error) {
+	p, err := OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	go d.Serve(p)
+	return p, nil
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package sim
+
+import "errors"
+
+// ErrPTYUnsupported is returned by OpenPTY on systems other than Linux.
+var ErrPTYUnsupported = errors.New("pseudo-terminals are only supported on Linux")
+
+// PTY is a pseudo-terminal (only available on Linux).
+type PTY struct {
+	Path string
+}
+
+// OpenPTY is only implemented on Linux.
+func OpenPTY() (*PTY, error) {
+	return nil, ErrPTYUnsupported
+}
+
+func (p *PTY) Read(b []byte) (int, error) {
+	return 0, ErrPTYUnsupported
+}
+
+func (p *PTY) Write(b []byte) (int, error) {
+	return 0, ErrPTYUnsupported
+}
+
+func (p *PTY) Close() error {
+	return nil
+}
+
+// ServePTY is only implemented on Linux.
+func (d *Device) ServePTY() (*PTY, error) {
+	return nil, ErrPTYUnsupported
+}
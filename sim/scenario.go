@@ -0,0 +1,207 @@
+package sim
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+)
+
+// Transition sets the state of a line at a given time after the start of a capture.
+// Transitions at or before time 0 define the state of the lines when the capture starts.
+type Transition struct {
+	At   time.Duration
+	Line string // e.g. "Opto1", "Mic2", "TTLin1" (see bbtkv3.InputPortNames)
+	High bool
+}
+
+// Scenario is the script of what the simulated sensors do during a capture.
+type Scenario []Transition
+
+// Pulses returns the transitions of count pulses of the given duration on line,
+// the first one starting at onset and the next ones every period.
+func Pulses(line string, onset, duration, period time.Duration, count int) Scenario {
+	var s Scenario
+	for i := 0; i < max(count, 1); i++ {
+		t := onset + time.Duration(i)*period
+		s = append(s, Transition{At: t, Line: line, High: true}, Transition{At: t + duration, Line: line, High: false})
+	}
+	return s
+}
+
+// ParseScenario reads a scenario file. Each non-empty line not starting with '#'
+// describes either a pulse:
+//
+//	<line> <onset> <duration> [<period> <count>]
+//
+// or a single transition:
+//
+//	<line> <time> up|down
+//
+// Times use the syntax of time.ParseDuration. For example:
+//
+//	# Opto1 flashes for 50ms every second, ten times, starting at 1s
+//	Opto1  1s    50ms  1s  10
+//	# Mic1 is active when the capture starts and goes down after 300ms
+//	Mic1   0s    up
+//	Mic1   300ms down
+func ParseScenario(r io.Reader) (Scenario, error) {
+	var s Scenario
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		line := fields[0]
+		if lineIndex(line) < 0 {
+			return nil, fmt.Errorf("line %d: unknown line %q", n, line)
+		}
+
+		if len(fields) == 3 && (fields[2] == "up" || fields[2] == "down") {
+			at, err := time.ParseDuration(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			s = append(s, Transition{At: at, Line: line, High: fields[2] == "up"})
+			continue
+		}
+
+		if len(fields) != 3 && len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected \"<line> <onset> <duration> [<period> <count>]\" or \"<line> <time> up|down\"", n)
+		}
+
+		var times []time.Duration
+		for _, f := range fields[1:min(len(fields), 4)] {
+			t, err := time.ParseDuration(f)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			times = append(times, t)
+		}
+
+		period, count := time.Duration(0), 1
+		if len(fields) == 5 {
+			period = times[2]
+			c, err := strconv.Atoi(fields[4])
+			if err != nil || c < 1 {
+				return nil, fmt.Errorf("line %d: invalid count %q", n, fields[4])
+			}
+			count = c
+		}
+
+		s = append(s, Pulses(line, times[0], times[1], period, count)...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadScenario reads a scenario from a file (see ParseScenario).
+func LoadScenario(filename string) (Scenario, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := ParseScenario(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return s, nil
+}
+
+// lineIndex returns the position of a line in the 20 state characters of a DSC record.
+func lineIndex(name string) int {
+	if i := slices.Index(bbtkv3.InputPortNames, name); i >= 0 {
+		return i
+	}
+	if i := slices.Index(bbtkv3.OutputPortNames, name); i >= 0 {
+		return len(bbtkv3.InputPortNames) + i
+	}
+	return -1
+}
+
+// Validate checks that all the lines of the scenario exist.
+func (s Scenario) Validate() error {
+	for _, t := range s {
+		if lineIndex(t.Line) < 0 {
+			return fmt.Errorf("unknown line %q at %v", t.Line, t.At)
+		}
+	}
+	return nil
+}
+
+// step is the state of the 20 lines (as in DSC records) from a given time.
+type step struct {
+	At    time.Duration
+	State string
+}
+
+// timeline returns the successive states of the lines: one step at time 0 with the initial
+// state, then one at each change.
+func (s Scenario) timeline() []step {
+	ts := slices.Clone(s)
+	slices.SortStableFunc(ts, func(a, b Transition) int {
+		return cmp.Compare(a.At, b.At)
+	})
+
+	state := []byte(strings.Repeat("0", len(bbtkv3.InputPortNames)+len(bbtkv3.OutputPortNames)))
+	apply := func(t Transition) {
+		if i := lineIndex(t.Line); i >= 0 {
+			state[i] = '0'
+			if t.High {
+				state[i] = '1'
+			}
+		}
+	}
+
+	i := 0
+	for ; i < len(ts) && ts[i].At <= 0; i++ {
+		apply(ts[i])
+	}
+	steps := []step{{At: 0, State: string(state)}}
+
+	for i < len(ts) {
+		at := ts[i].At
+		prev := string(state)
+		for ; i < len(ts) && ts[i].At == at; i++ {
+			apply(ts[i])
+		}
+		if string(state) != prev {
+			steps = append(steps, step{At: at, State: string(state)})
+		}
+	}
+
+	return steps
+}
+
+// records returns the DSC records (20 line states followed by a 12-digit timestamp in µs)
+// generated by the scenario during a capture of the given duration.
+// A record is produced at each change of the lines, plus one at time 0 if some
+// lines are already high when the capture starts.
+func (s Scenario) records(duration time.Duration) []string {
+	var recs []string
+	for _, st := range s.timeline() {
+		if st.At >= duration {
+			break
+		}
+		if st.At == 0 && !strings.Contains(st.State, "1") {
+			continue
+		}
+		recs = append(recs, fmt.Sprintf("%s%012d", st.State, st.At.Microseconds()))
+	}
+	return recs
+}
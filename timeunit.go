@@ -0,0 +1,95 @@
+package bbtkv3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TimeUnit is the unit of the times written to CSV files. The times are kept in µs, the
+// resolution of the BBTK, and written as exact decimals, so that they can be read back
+// without loss (see TimeUnit.Parse).
+type TimeUnit int
+
+const (
+	Milliseconds TimeUnit = iota // with 3 decimals
+	Microseconds
+	Seconds // with 6 decimals
+)
+
+// ParseTimeUnit parses the name of a unit: "us" (or "µs"), "ms" or "s".
+func ParseTimeUnit(s string) (TimeUnit, error) {
+	switch s {
+	case "us", "µs":
+		return Microseconds, nil
+	case "ms":
+		return Milliseconds, nil
+	case "s":
+		return Seconds, nil
+	}
+	return 0, fmt.Errorf("unknown time unit %q (expected us, ms or s)", s)
+}
+
+func (u TimeUnit) String() string {
+	switch u {
+	case Microseconds:
+		return "us"
+	case Milliseconds:
+		return "ms"
+	case Seconds:
+		return "s"
+	}
+	return fmt.Sprintf("TimeUnit(%d)", int(u))
+}
+
+// decimals returns the number of decimals of the times in unit u, and the number of µs in one u.
+func (u TimeUnit) decimals() (int, int64) {
+	switch u {
+	case Milliseconds:
+		return 3, 1000
+	case Seconds:
+		return 6, 1000000
+	}
+	return 0, 1
+}
+
+// Format formats the time us (in µs) in unit u, exactly, e.g. 1234567 as "1234.567" in ms.
+func (u TimeUnit) Format(us int64) string {
+	n, scale := u.decimals()
+	if n == 0 {
+		return strconv.FormatInt(us, 10)
+	}
+	sign := ""
+	if us < 0 {
+		sign, us = "-", -us
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, us/scale, n, us%scale)
+}
+
+// Parse parses a time in unit u, as written by Format, and returns it in µs. Times more precise
+// than the µs are rejected.
+func (u TimeUnit) Parse(s string) (int64, error) {
+	n, scale := u.decimals()
+	s = strings.TrimSpace(s)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > n {
+		return 0, fmt.Errorf("time %q in %v: more precise than 1µs", s, u)
+	}
+	neg := strings.HasPrefix(whole, "-")
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", s, err)
+	}
+	var f int64
+	if frac != "" {
+		if f, err = strconv.ParseInt(frac+strings.Repeat("0", n-len(frac)), 10, 64); err != nil || f < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	if neg {
+		f = -f
+	}
+	return w*scale + f, nil
+}
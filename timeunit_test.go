@@ -0,0 +1,78 @@
+package bbtkv3
+
+import "testing"
+
+func TestTimeUnitFormat(t *testing.T) {
+	tests := []struct {
+		unit TimeUnit
+		us   int64
+		want string
+	}{
+		{Milliseconds, 1234567, "1234.567"},
+		{Milliseconds, 5, "0.005"},
+		{Milliseconds, -1500, "-1.500"},
+		{Seconds, 30000001, "30.000001"},
+		{Seconds, -5, "-0.000005"},
+		{Microseconds, 1234567, "1234567"},
+	}
+	for _, tt := range tests {
+		if got := tt.unit.Format(tt.us); got != tt.want {
+			t.Errorf("%v.Format(%d) = %q, want %q", tt.unit, tt.us, got, tt.want)
+		}
+	}
+}
+
+func TestTimeUnitRoundTrip(t *testing.T) {
+	for _, u := range []TimeUnit{Microseconds, Milliseconds, Seconds} {
+		for _, us := range []int64{0, 1, 999, 1000, 123456789, 999999999999, -1, -20000} {
+			s := u.Format(us)
+			got, err := u.Parse(s)
+			if err != nil || got != us {
+				t.Errorf("%v: %d formatted as %q, parsed as %d, %v", u, us, s, got, err)
+			}
+		}
+	}
+}
+
+func TestTimeUnitParse(t *testing.T) {
+	tests := []struct {
+		unit TimeUnit
+		s    string
+		want int64
+	}{
+		{Milliseconds, "12", 12000},
+		{Milliseconds, "12.5", 12500},
+		{Milliseconds, " -0.25 ", -250},
+		{Seconds, "1.5", 1500000},
+	}
+	for _, tt := range tests {
+		if got, err := tt.unit.Parse(tt.s); err != nil || got != tt.want {
+			t.Errorf("%v.Parse(%q) = %d, %v, want %d", tt.unit, tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		unit TimeUnit
+		s    string
+	}{
+		{Milliseconds, "1.2345"}, // more precise than 1µs
+		{Microseconds, "1.5"},
+		{Seconds, "1.-5"},
+		{Milliseconds, "abc"},
+	} {
+		if got, err := tt.unit.Parse(tt.s); err == nil {
+			t.Errorf("%v.Parse(%q) = %d, want an error", tt.unit, tt.s, got)
+		}
+	}
+}
+
+func TestParseTimeUnit(t *testing.T) {
+	for _, u := range []TimeUnit{Microseconds, Milliseconds, Seconds} {
+		if got, err := ParseTimeUnit(u.String()); err != nil || got != u {
+			t.Errorf("ParseTimeUnit(%q) = %v, %v", u.String(), got, err)
+		}
+	}
+	if _, err := ParseTimeUnit("min"); err == nil {
+		t.Error("ParseTimeUnit(\"min\"): no error")
+	}
+}
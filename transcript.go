@@ -0,0 +1,318 @@
+package bbtkv3
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations recorded in a transcript.
+const (
+	OpWrite       = "write"        // bytes sent to the BBTK
+	OpRead        = "read"         // bytes received from the BBTK
+	OpTimeout     = "timeout"      // a read which timed out
+	OpResetInput  = "reset-input"  // input buffer purged
+	OpResetOutput = "reset-output" // output buffer purged
+	OpBreak       = "break"        // serial break sent
+	OpClose       = "close"
+)
+
+// TranscriptEntry is one operation on a transport, timestamped relatively to the
+// start of the recording (using the monotonic clock of the host).
+type TranscriptEntry struct {
+	Time time.Duration
+	Op   string
+	Data []byte
+}
+
+// String formats the entry as a line of a transcript file, e.g.
+//
+//	0.150321 read "BBTK;\n"
+func (e TranscriptEntry) String() string {
+	s := fmt.Sprintf("%.6f %s", e.Time.Seconds(), e.Op)
+	if e.Op == OpWrite || e.Op == OpRead {
+		s += " " + strconv.Quote(string(e.Data))
+	}
+	return s
+}
+
+// Recorder is a Transport which records all the traffic going through another
+// Transport into a transcript. Transcripts can be attached to bug reports and
+// replayed with a Replayer.
+type Recorder struct {
+	t     Transport
+	w     io.Writer
+	start time.Time
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewRecorder returns a Recorder writing the transcript of the traffic through t to w.
+func NewRecorder(t Transport, w io.Writer) *Recorder {
+	r := &Recorder{t: t, w: w, start: time.Now()}
+	r.mu.Lock()
+	_, r.err = fmt.Fprintf(w, "# bbtkv3 transcript, started %s\n", r.start.Format(time.RFC3339))
+	r.mu.Unlock()
+	return r
+}
+
+func (r *Recorder) record(op string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	e := TranscriptEntry{Time: time.Since(r.start), Op: op, Data: data}
+	_, r.err = fmt.Fprintln(r.w, e)
+}
+
+// Err returns the first error which occurred while writing the transcript.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.t.Read(p)
+	if n > 0 {
+		r.record(OpRead, p[:n])
+	} else if err == nil {
+		r.record(OpTimeout, nil)
+	}
+	return n, err
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	n, err := r.t.Write(p)
+	r.record(OpWrite, p[:n])
+	return n, err
+}
+
+func (r *Recorder) Close() error {
+	r.record(OpClose, nil)
+	return r.t.Close()
+}
+
+func (r *Recorder) ResetInputBuffer() error {
+	r.record(OpResetInput, nil)
+	return r.t.ResetInputBuffer()
+}
+
+func (r *Recorder) ResetOutputBuffer() error {
+	r.record(OpResetOutput, nil)
+	return r.t.ResetOutputBuffer()
+}
+
+func (r *Recorder) SetReadTimeout(t time.Duration) error {
+	return r.t.SetReadTimeout(t)
+}
+
+// Break sends a serial break through the recorded transport, if it can.
+func (r *Recorder) Break(d time.Duration) error {
+	br, ok := r.t.(Breaker)
+	if !ok {
+		return errors.New("the transport cannot send a serial break")
+	}
+	r.record(OpBreak, nil)
+	return br.Break(d)
+}
+
+// ReadTranscript parses a transcript written by a Recorder.
+func ReadTranscript(r io.Reader) ([]TranscriptEntry, error) {
+	var entries []TranscriptEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("transcript line %d: invalid entry %q", n, line)
+		}
+
+		secs, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("transcript line %d: invalid time: %w", n, err)
+		}
+		e := TranscriptEntry{Time: time.Duration(secs * float64(time.Second)), Op: fields[1]}
+
+		switch e.Op {
+		case OpWrite, OpRead:
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("transcript line %d: missing data", n)
+			}
+			data, err := strconv.Unquote(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("transcript line %d: invalid data: %w", n, err)
+			}
+			e.Data = []byte(data)
+		case OpTimeout, OpResetInput, OpResetOutput, OpBreak, OpClose:
+		default:
+			return nil, fmt.Errorf("transcript line %d: unknown operation %q", n, e.Op)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// LoadTranscript reads a transcript file.
+func LoadTranscript(filename string) ([]TranscriptEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := ReadTranscript(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return entries, nil
+}
+
+// Replayer is a Transport which plays the part of the BBTK in a recorded session.
+// The bytes written to it must be those of the transcript, in the same order
+// (otherwise Write fails with ErrTranscriptMismatch); reads return the bytes that
+// the BBTK sent at that point of the session. Replay does not wait: it runs as fast
+// as the program reading it.
+type Replayer struct {
+	entries []TranscriptEntry
+
+	mu      sync.Mutex
+	pos     int    // next entry
+	pending []byte // rest of the current read entry
+	written []byte // bytes written and not yet matched against write entries
+	timeout time.Duration
+}
+
+// NewReplayer returns a Replayer playing entries.
+func NewReplayer(entries []TranscriptEntry) *Replayer {
+	return &Replayer{entries: entries, timeout: time.Second}
+}
+
+// skip advances over the entries which are neither reads nor writes;
+// over timeouts too if timeouts is true.
+func (r *Replayer) skip(timeouts bool) {
+	for r.pos < len(r.entries) {
+		switch r.entries[r.pos].Op {
+		case OpRead, OpWrite:
+			return
+		case OpTimeout:
+			if !timeouts {
+				return
+			}
+		}
+		r.pos++
+	}
+}
+
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.mu.Lock()
+
+	if len(r.pending) == 0 {
+		r.skip(false)
+		if r.pos < len(r.entries) {
+			switch e := r.entries[r.pos]; e.Op {
+			case OpRead:
+				r.pending = e.Data
+				r.pos++
+			case OpTimeout:
+				r.pos++
+				r.mu.Unlock()
+				return 0, nil
+			}
+		}
+	}
+
+	if len(r.pending) == 0 {
+		// Nothing to read at this point of the session: behave like a silent port.
+		timeout := r.timeout
+		r.mu.Unlock()
+		time.Sleep(timeout)
+		return 0, nil
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.mu.Unlock()
+	return n, nil
+}
+
+func (r *Replayer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.written = append(r.written, p...)
+	for len(r.written) > 0 {
+		r.skip(true)
+		if r.pos == len(r.entries) || r.entries[r.pos].Op != OpWrite {
+			return 0, fmt.Errorf("%w: unexpected write %q", ErrTranscriptMismatch, r.written)
+		}
+
+		expected := r.entries[r.pos].Data
+		switch {
+		case bytes.HasPrefix(r.written, expected):
+			r.written = r.written[len(expected):]
+			r.pos++
+		case bytes.HasPrefix(expected, r.written):
+			return len(p), nil // the rest of the entry will come with the next writes
+		default:
+			return 0, fmt.Errorf("%w: expected write %q, got %q", ErrTranscriptMismatch, expected, r.written)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close does nothing.
+func (r *Replayer) Close() error {
+	return nil
+}
+
+// ResetInputBuffer drops what remains of the current read entry.
+func (r *Replayer) ResetInputBuffer() error {
+	r.mu.Lock()
+	r.pending = nil
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Replayer) ResetOutputBuffer() error {
+	return nil
+}
+
+// Break does nothing.
+func (r *Replayer) Break(d time.Duration) error {
+	return nil
+}
+
+// SetReadTimeout sets how long reads wait when the transcript has nothing to offer.
+func (r *Replayer) SetReadTimeout(t time.Duration) error {
+	r.mu.Lock()
+	r.timeout = t
+	r.mu.Unlock()
+	return nil
+}
+
+// Done tells if the whole transcript has been replayed.
+func (r *Replayer) Done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skip(true)
+	return r.pos == len(r.entries) && len(r.pending) == 0
+}
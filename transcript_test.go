@@ -0,0 +1,107 @@
+package bbtkv3_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chrplr/bbtkv3"
+	"github.com/chrplr/bbtkv3/sim"
+)
+
+var update = flag.Bool("update", false, "record the transcripts of testdata again, from the simulator")
+
+// sessionTranscript is the transcript of a session with the simulator: no transcript of a real BBTK
+// is available yet. It is recorded again, from sessionScenario, with go test -update.
+var sessionTranscript = filepath.Join("testdata", "session.transcript")
+
+// sessionScenario is played by the simulator during the capture of the session: Opto1 is active at
+// the start of the capture, and Keypad1 at its end.
+var sessionScenario = append(
+	append(sim.Pulses("Mic1", 100*time.Millisecond, 50*time.Millisecond, 300*time.Millisecond, 3),
+		sim.Transition{At: 0, Line: "Opto1", High: true},
+		sim.Transition{At: 250 * time.Millisecond, Line: "Opto1", High: false}),
+	sim.Transition{At: 900 * time.Millisecond, Line: "Keypad1", High: true},
+)
+
+// session runs Connect, GetThresholds, ClearTimingData and a capture of 1s over t, and checks
+// their results.
+func session(t *testing.T, tr bbtkv3.Transport) {
+	t.Helper()
+	b := bbtkv3.NewBbtkv3FromTransport(tr, nil)
+
+	if err := b.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Firmware().Model; got != bbtkv3.ModelV3 {
+		t.Errorf("model: got %v, want %v", got, bbtkv3.ModelV3)
+	}
+
+	thresholds, err := b.GetThresholds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bbtkv3.Thresholds{
+		Mic1: 63, Mic2: 63, Sounder1: 63, Sounder2: 63,
+		Opto1: 63, Opto2: 63, Opto3: 63, Opto4: 63,
+	}
+	if thresholds != want {
+		t.Errorf("thresholds: got %+v, want %+v", thresholds, want)
+	}
+
+	if err := b.ClearTimingData(); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := b.CaptureEvents(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := bbtkv3.CaptureOutputToEvents(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := bbtkv3.CaptureEventsFromDSCEvents(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEvents := []bbtkv3.Event{
+		{Type: "Keypad1", Onset: 900000, Duration: 0, ActiveAtEnd: true},
+		{Type: "Opto1", Onset: 0, Duration: 250000, ActiveAtStart: true},
+		{Type: "Mic1", Onset: 100000, Duration: 50000},
+		{Type: "Mic1", Onset: 400000, Duration: 50000},
+		{Type: "Mic1", Onset: 700000, Duration: 50000},
+	}
+	if !reflect.DeepEqual(events, wantEvents) {
+		t.Errorf("events:\ngot  %+v\nwant %+v", events, wantEvents)
+	}
+}
+
+func TestSessionTranscript(t *testing.T) {
+	if *update {
+		f, err := os.Create(sessionTranscript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rec := bbtkv3.NewRecorder(sim.New(sessionScenario).Pipe(), f)
+		session(t, rec)
+		if err := rec.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	entries, err := bbtkv3.LoadTranscript(sessionTranscript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bbtkv3.NewReplayer(entries)
+	session(t, r)
+	if !r.Done() {
+		t.Error("the transcript was not replayed to its end")
+	}
+}
@@ -0,0 +1,126 @@
+package bbtkv3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Transport is the byte stream over which the BBTK protocol is spoken.
+// A serial.Port satisfies it, but so can a pipe, a pty, a TCP socket or a
+// test double.
+//
+// Read must honour the read timeout set with SetReadTimeout: when no byte
+// arrives in time it returns (0, nil), as serial ports do.
+type Transport interface {
+	io.ReadWriteCloser
+
+	// ResetInputBuffer discards the bytes received but not yet read.
+	ResetInputBuffer() error
+
+	// ResetOutputBuffer discards the bytes written but not yet transmitted.
+	ResetOutputBuffer() error
+
+	// SetReadTimeout sets how long Read waits for data before giving up.
+	SetReadTimeout(t time.Duration) error
+}
+
+// Breaker is implemented by the transports which can send a serial break, as serial ports do.
+type Breaker interface {
+	Break(d time.Duration) error
+}
+
+// OpenSerial opens the serial device at portAddress with the settings used by the BBTK
+// (8 data bits, no parity, one stop bit).
+func OpenSerial(portAddress string, baudrate int) (Transport, error) {
+	mode := &serial.Mode{
+		BaudRate: baudrate,
+		Parity:   serial.NoParity,
+		DataBits: 8,
+		StopBits: serial.OneStopBit,
+	}
+
+	port, err := serial.Open(portAddress, mode)
+	if err != nil {
+		return nil, fmt.Errorf("error while trying to open %s (at %d bps): %w (Under Linux, try `sudo modprobe ftdi_sio`)", portAddress, baudrate, err)
+	}
+
+	return port, nil
+}
+
+// link is the transport as seen by a Bbtkv3 object: reads which time out fail with
+// ErrTimeout, I/O fails with ErrNotConnected once the link has been closed, and
+// other I/O errors, which mean that the port is gone, are reported as ErrPortLost.
+type link struct {
+	t         Transport
+	closed    bool
+	opened    time.Time // for the timing of the protocol trace
+	lastWrite time.Time
+}
+
+func (l *link) Read(p []byte) (int, error) {
+	if l.closed {
+		return 0, ErrNotConnected
+	}
+	n, err := l.t.Read(p)
+	if n == 0 && err == nil {
+		return 0, ErrTimeout
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrPortLost, err)
+	}
+	return n, err
+}
+
+func (l *link) Write(p []byte) (int, error) {
+	if l.closed {
+		return 0, ErrNotConnected
+	}
+	l.lastWrite = time.Now()
+	n, err := l.t.Write(p)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrPortLost, err)
+	}
+	return n, err
+}
+
+func (l *link) Close() error {
+	if l.closed {
+		return ErrNotConnected
+	}
+	l.closed = true
+	return l.t.Close()
+}
+
+func (l *link) ResetInputBuffer() error {
+	if l.closed {
+		return ErrNotConnected
+	}
+	return l.t.ResetInputBuffer()
+}
+
+func (l *link) ResetOutputBuffer() error {
+	if l.closed {
+		return ErrNotConnected
+	}
+	return l.t.ResetOutputBuffer()
+}
+
+func (l *link) SetReadTimeout(t time.Duration) error {
+	return l.t.SetReadTimeout(t)
+}
+
+// Break sends a serial break of duration d, if the transport can.
+func (l *link) Break(d time.Duration) error {
+	if l.closed {
+		return ErrNotConnected
+	}
+	br, ok := l.t.(Breaker)
+	if !ok {
+		return errors.New("the transport cannot send a serial break")
+	}
+	return br.Break(d)
+}